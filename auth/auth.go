@@ -0,0 +1,178 @@
+// Package auth provides Authenticators for Bernard, which fetch access tokens
+// for the Google Drive API from Google's OAuth 2.0 token endpoint.
+//
+// Every Authenticator caches its access token until shortly before it expires
+// and is safe for concurrent use.
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/m-rots/bernard"
+)
+
+// GoogleTokenURL is the default token endpoint of Google's OAuth 2.0 server.
+const GoogleTokenURL = "https://oauth2.googleapis.com/token"
+
+// The scopes of the Google Drive API.
+const (
+	DriveScope         = "https://www.googleapis.com/auth/drive"
+	DriveReadOnlyScope = "https://www.googleapis.com/auth/drive.readonly"
+)
+
+// expiryDelta is the time before the expiry of an access token at which it is refreshed,
+// so a token does not expire in-between its retrieval and its use.
+const expiryDelta = time.Minute
+
+// ErrInvalidKey occurs when the service account key cannot be parsed.
+var ErrInvalidKey = errors.New("auth: invalid service account key")
+
+// ErrInvalidGrant occurs when the token endpoint rejects the grant,
+// for example when a refresh token has been revoked or has expired.
+// The grant has to be renewed, as retrying the request will not help.
+//
+// ErrInvalidGrant wraps bernard.ErrInvalidCredentials.
+var ErrInvalidGrant = fmt.Errorf("auth: invalid grant: %w", bernard.ErrInvalidCredentials)
+
+// An Option can override some of the default Authenticator values.
+type Option func(*options)
+
+type options struct {
+	client   *http.Client
+	tokenURL string
+	scopes   []string
+	subject  string
+}
+
+// WithClient allows one to override the default HTTP client.
+func WithClient(client *http.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithTokenURL overrides the token endpoint the access tokens are requested at.
+//
+// By default, a ServiceAccount uses the token_uri of its key,
+// and GoogleTokenURL when the key does not provide one.
+// A User uses GoogleTokenURL by default.
+func WithTokenURL(tokenURL string) Option {
+	return func(o *options) {
+		o.tokenURL = tokenURL
+	}
+}
+
+// WithScopes sets the scopes of the access tokens of a ServiceAccount.
+// The scopes of a User are fixed by the grant of its refresh token.
+//
+// The default scope is DriveScope.
+func WithScopes(scopes ...string) Option {
+	return func(o *options) {
+		o.scopes = scopes
+	}
+}
+
+// WithSubject makes a ServiceAccount impersonate the user with the email address of the subject,
+// which requires domain-wide delegation to be enabled for the service account.
+func WithSubject(subject string) Option {
+	return func(o *options) {
+		o.subject = subject
+	}
+}
+
+func newOptions(tokenURL string, opts []Option) options {
+	if tokenURL == "" {
+		tokenURL = GoogleTokenURL
+	}
+
+	o := options{
+		client: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+		tokenURL: tokenURL,
+		scopes:   []string{DriveScope},
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// A token is an access token with its expiry time.
+// The token endpoint may also return a new refresh token.
+type token struct {
+	accessToken  string
+	refreshToken string
+	expiry       time.Time
+}
+
+// valid reports whether the token can still be used for at least the expiryDelta.
+func (t token) valid(now time.Time) bool {
+	return t.accessToken != "" && now.Add(expiryDelta).Before(t.expiry)
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type errorResponse struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// requestToken exchanges the form at the token endpoint for an access token.
+//
+// A rejected grant results in ErrInvalidGrant and any other rejected request in bernard.ErrInvalidCredentials,
+// while any other failure results in bernard.ErrNetwork.
+func (o *options) requestToken(form url.Values) (token, error) {
+	now := time.Now()
+
+	res, err := o.client.PostForm(o.tokenURL, form)
+	if err != nil {
+		return token{}, bernard.ErrNetwork
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		response := new(errorResponse)
+		json.NewDecoder(res.Body).Decode(response)
+
+		message := strings.TrimSpace(response.Error + " " + response.Description)
+		if message == "" {
+			message = res.Status
+		}
+
+		switch {
+		case response.Error == "invalid_grant":
+			return token{}, fmt.Errorf("%v: %w", message, ErrInvalidGrant)
+		case res.StatusCode == http.StatusBadRequest,
+			res.StatusCode == http.StatusUnauthorized,
+			res.StatusCode == http.StatusForbidden:
+			return token{}, fmt.Errorf("%v: %w", message, bernard.ErrInvalidCredentials)
+		default:
+			return token{}, fmt.Errorf("%v: %w", message, bernard.ErrNetwork)
+		}
+	}
+
+	response := new(tokenResponse)
+	if err := json.NewDecoder(res.Body).Decode(response); err != nil || response.AccessToken == "" {
+		return token{}, fmt.Errorf("token response: %w", bernard.ErrNetwork)
+	}
+
+	return token{
+		accessToken:  response.AccessToken,
+		refreshToken: response.RefreshToken,
+		expiry:       now.Add(time.Duration(response.ExpiresIn) * time.Second),
+	}, nil
+}
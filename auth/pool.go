@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/m-rots/bernard"
+)
+
+// DefaultCooldown is the default duration an account of a Pool is skipped for
+// once the Drive API has rate-limited one of its requests.
+const DefaultCooldown = time.Minute
+
+// A PoolOption can override some of the default Pool values.
+type PoolOption func(*Pool)
+
+// WithCooldown overrides the duration a rate-limited account is skipped for.
+func WithCooldown(cooldown time.Duration) PoolOption {
+	return func(pool *Pool) {
+		pool.cooldown = cooldown
+	}
+}
+
+// Pool is an Authenticator which spreads the requests over a pool of accounts,
+// such as several service accounts, to spread the Drive API quota.
+//
+// Every call to AccessToken hands out the access token of the next account in turn.
+// Pool implements the bernard.RateLimitedAuthenticator interface,
+// so an account which gets rate-limited is skipped until its cooldown has passed.
+// When all accounts are cooling down, the account whose cooldown ends first is used.
+type Pool struct {
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	next     int
+	accounts []*poolAccount
+}
+
+type poolAccount struct {
+	auth  bernard.Authenticator
+	token string
+	AccountStatus
+}
+
+// AccountStatus is the status of an account within a Pool.
+type AccountStatus struct {
+	// Requests is the number of access tokens handed out for the account.
+	Requests int
+
+	// RateLimited is the number of times a request of the account has been rate-limited.
+	RateLimited int
+
+	// CooldownUntil is the time until which the account is skipped,
+	// and is zero when the account has never been rate-limited.
+	CooldownUntil time.Time
+}
+
+// NewPool creates a Pool of the provided accounts.
+func NewPool(accounts []bernard.Authenticator, opts ...PoolOption) *Pool {
+	pool := &Pool{
+		cooldown: DefaultCooldown,
+		now:      time.Now,
+	}
+
+	for _, auth := range accounts {
+		pool.accounts = append(pool.accounts, &poolAccount{auth: auth})
+	}
+
+	for _, opt := range opts {
+		opt(pool)
+	}
+
+	return pool
+}
+
+// AccessToken returns the access token of the next account which is not cooling down.
+// The expiry time is returned in UNIX.
+func (pool *Pool) AccessToken() (string, int64, error) {
+	if len(pool.accounts) == 0 {
+		return "", 0, fmt.Errorf("empty pool: %w", bernard.ErrInvalidCredentials)
+	}
+
+	pool.mu.Lock()
+	account := pool.pick()
+	account.Requests++
+	pool.mu.Unlock()
+
+	// The lock is not held while the account fetches its token,
+	// so other accounts can hand out their tokens in the meantime.
+	token, exp, err := account.auth.AccessToken()
+	if err != nil {
+		return "", 0, err
+	}
+
+	pool.mu.Lock()
+	account.token = token
+	pool.mu.Unlock()
+
+	return token, exp, nil
+}
+
+// pick returns the next account in turn which is not cooling down,
+// or the account whose cooldown ends first.
+func (pool *Pool) pick() *poolAccount {
+	now := pool.now()
+
+	var earliest *poolAccount
+	for i := range pool.accounts {
+		index := (pool.next + i) % len(pool.accounts)
+		account := pool.accounts[index]
+
+		if !account.CooldownUntil.After(now) {
+			pool.next = index + 1
+			return account
+		}
+
+		if earliest == nil || account.CooldownUntil.Before(earliest.CooldownUntil) {
+			earliest = account
+		}
+	}
+
+	return earliest
+}
+
+// RateLimited starts the cooldown of the account the access token belongs to.
+// It reports whether any account is available which is not cooling down.
+func (pool *Pool) RateLimited(accessToken string) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	now := pool.now()
+	available := false
+
+	for _, account := range pool.accounts {
+		if account.token == accessToken {
+			account.RateLimited++
+			account.CooldownUntil = now.Add(pool.cooldown)
+		}
+
+		if !account.CooldownUntil.After(now) {
+			available = true
+		}
+	}
+
+	return available
+}
+
+// Status returns the status of every account, in the order the accounts were provided.
+func (pool *Pool) Status() []AccountStatus {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	status := make([]AccountStatus, len(pool.accounts))
+	for i, account := range pool.accounts {
+		status[i] = account.AccountStatus
+	}
+
+	return status
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/m-rots/bernard"
+)
+
+type staticToken string
+
+func (token staticToken) AccessToken() (string, int64, error) {
+	return string(token), 0, nil
+}
+
+func TestPool(t *testing.T) {
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	pool := NewPool([]bernard.Authenticator{staticToken("a"), staticToken("b"), staticToken("c")},
+		WithCooldown(time.Minute))
+
+	pool.now = func() time.Time { return now }
+
+	var _ bernard.RateLimitedAuthenticator = pool
+
+	// next returns the tokens of the next n calls to AccessToken.
+	next := func(n int) (tokens []string) {
+		for i := 0; i < n; i++ {
+			token, _, err := pool.AccessToken()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tokens = append(tokens, token)
+		}
+
+		return tokens
+	}
+
+	if tokens := next(4); !reflect.DeepEqual(tokens, []string{"a", "b", "c", "a"}) {
+		t.Errorf("Expected round-robin, got: %v", tokens)
+	}
+
+	if !pool.RateLimited("b") {
+		t.Error("Expected accounts a and c to be available")
+	}
+
+	if tokens := next(3); !reflect.DeepEqual(tokens, []string{"c", "a", "c"}) {
+		t.Errorf("Expected account b to be skipped, got: %v", tokens)
+	}
+
+	now = now.Add(30 * time.Second)
+	pool.RateLimited("a")
+
+	if pool.RateLimited("c") {
+		t.Error("Expected all accounts to be cooling down")
+	}
+
+	// Account b cools down first.
+	if tokens := next(2); !reflect.DeepEqual(tokens, []string{"b", "b"}) {
+		t.Errorf("Expected the account with the earliest cooldown, got: %v", tokens)
+	}
+
+	now = now.Add(time.Minute)
+	if tokens := next(3); !reflect.DeepEqual(tokens, []string{"a", "b", "c"}) {
+		t.Errorf("Expected round-robin after the cooldowns, got: %v", tokens)
+	}
+
+	expected := []AccountStatus{
+		{Requests: 4, RateLimited: 1, CooldownUntil: now},
+		{Requests: 4, RateLimited: 1, CooldownUntil: now.Add(-30 * time.Second)},
+		{Requests: 4, RateLimited: 1, CooldownUntil: now},
+	}
+
+	if status := pool.Status(); !reflect.DeepEqual(status, expected) {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
+
+func TestEmptyPool(t *testing.T) {
+	if _, _, err := NewPool(nil).AccessToken(); !errors.Is(err, bernard.ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials, got: %v", err)
+	}
+}
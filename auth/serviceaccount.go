@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// assertionLifetime is the lifetime of the signed assertion,
+// which is the maximum lifetime Google allows.
+const assertionLifetime = time.Hour
+
+// ServiceAccount is an Authenticator which signs JWT assertions with the key of a Google service account
+// and exchanges these for access tokens.
+type ServiceAccount struct {
+	options
+
+	email string
+	key   *rsa.PrivateKey
+
+	mu    sync.Mutex
+	token token
+}
+
+type serviceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// NewServiceAccount creates a ServiceAccount from the JSON key of a Google service account.
+func NewServiceAccount(key []byte, opts ...Option) (*ServiceAccount, error) {
+	sa := new(serviceAccountKey)
+	if err := json.Unmarshal(key, sa); err != nil {
+		return nil, fmt.Errorf("json: %w", ErrInvalidKey)
+	}
+
+	if sa.ClientEmail == "" {
+		return nil, fmt.Errorf("client_email: %w", ErrInvalidKey)
+	}
+
+	priv, err := parseKey(sa.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceAccount{
+		options: newOptions(sa.TokenURI, opts),
+		email:   sa.ClientEmail,
+		key:     priv,
+	}, nil
+}
+
+// NewServiceAccountFromFile creates a ServiceAccount from the JSON key file of a Google service account.
+func NewServiceAccountFromFile(path string, opts ...Option) (*ServiceAccount, error) {
+	key, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewServiceAccount(key, opts...)
+}
+
+// Impersonate returns a ServiceAccount which impersonates the user with the email address of the subject.
+// It shares the key and options of the service account, but caches its own access tokens.
+//
+// This allows a single service account to synchronise Shared Drives on behalf of different users.
+func (acc *ServiceAccount) Impersonate(subject string) *ServiceAccount {
+	opts := acc.options
+	opts.subject = subject
+
+	return &ServiceAccount{
+		options: opts,
+		email:   acc.email,
+		key:     acc.key,
+	}
+}
+
+// parseKey parses the PEM encoded RSA private key of a service account.
+func parseKey(priv string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(priv))
+	if block == nil {
+		return nil, fmt.Errorf("private_key: %w", ErrInvalidKey)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("private_key: %w", ErrInvalidKey)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private_key is not an RSA key: %w", ErrInvalidKey)
+	}
+
+	return rsaKey, nil
+}
+
+// AccessToken returns a cached access token, or requests a new one
+// when the cached token is about to expire. The expiry time is returned in UNIX.
+func (acc *ServiceAccount) AccessToken() (string, int64, error) {
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	if !acc.token.valid(time.Now()) {
+		assertion, err := acc.assertion(time.Now())
+		if err != nil {
+			return "", 0, err
+		}
+
+		t, err := acc.requestToken(url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		})
+
+		if err != nil {
+			return "", 0, err
+		}
+
+		acc.token = t
+	}
+
+	return acc.token.accessToken, acc.token.expiry.Unix(), nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+type jwtClaims struct {
+	Iss   string `json:"iss"`
+	Sub   string `json:"sub,omitempty"`
+	Scope string `json:"scope"`
+	Aud   string `json:"aud"`
+	Exp   int64  `json:"exp"`
+	Iat   int64  `json:"iat"`
+}
+
+// assertion returns a JWT signed with RS256, asserting the identity of the service account.
+func (acc *ServiceAccount) assertion(now time.Time) (string, error) {
+	header, err := encodeSegment(jwtHeader{Alg: "RS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := encodeSegment(jwtClaims{
+		Iss:   acc.email,
+		Sub:   acc.subject,
+		Scope: strings.Join(acc.scopes, " "),
+		Aud:   acc.tokenURL,
+		Iat:   now.Unix(),
+		Exp:   now.Add(assertionLifetime).Unix(),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	message := header + "." + claims
+	hashed := sha256.Sum256([]byte(message))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, acc.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", fmt.Errorf("signature: %w", ErrInvalidKey)
+	}
+
+	return message + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// encodeSegment encodes a segment of a JWT as Base64 encoded JSON.
+func encodeSegment(segment interface{}) (string, error) {
+	b, err := json.Marshal(segment)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/m-rots/bernard"
+)
+
+const clientEmail = "bernard@westworld.iam.gserviceaccount.com"
+
+// testKey is generated once, as generating RSA keys is slow.
+var testKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	return key
+}()
+
+// serviceAccountJSON returns the JSON key of a service account with the testKey.
+func serviceAccountJSON(t *testing.T, tokenURI string) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": clientEmail,
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    tokenURI,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+// verifyAssertion verifies the signature of the JWT and returns its claims.
+func verifyAssertion(assertion string) (*jwtClaims, error) {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("assertion has %d parts", len(parts))
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&testKey.PublicKey, crypto.SHA256, hashed[:], signature); err != nil {
+		return nil, err
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	claims := new(jwtClaims)
+	return claims, json.Unmarshal(b, claims)
+}
+
+type tokenServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	called int
+	claims *jwtClaims
+}
+
+// newTokenServer starts a fake token endpoint which responds with the provided expires_in,
+// or with the status and error when status is not 200.
+func newTokenServer(t *testing.T, expiresIn int64, status int, errCode string) *tokenServer {
+	ts := new(tokenServer)
+
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		ts.called++
+
+		if r.Method != http.MethodPost || r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("Unexpected request: %s %v", r.Method, r.Form)
+		}
+
+		claims, err := verifyAssertion(r.FormValue("assertion"))
+		if err != nil {
+			t.Errorf("Invalid assertion: %v", err)
+		}
+
+		ts.claims = claims
+
+		w.Header().Set("Content-Type", "application/json")
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(errorResponse{Error: errCode, Description: "test error"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token%d", ts.called),
+			"expires_in":   expiresIn,
+			"token_type":   "Bearer",
+		})
+	}))
+
+	return ts
+}
+
+func TestServiceAccount(t *testing.T) {
+	server := newTokenServer(t, 3600, http.StatusOK, "")
+	defer server.Close()
+
+	// The token_uri of the key is used by default.
+	acc, err := NewServiceAccount(serviceAccountJSON(t, server.URL),
+		WithScopes(DriveScope, DriveReadOnlyScope))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var _ bernard.Authenticator = acc
+
+	for i := 0; i < 3; i++ {
+		token, exp, err := acc.AccessToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token != "token1" || exp == 0 {
+			t.Errorf("Unexpected token: %s, %d", token, exp)
+		}
+	}
+
+	if server.called != 1 {
+		t.Errorf("Expected the token to be cached, but the server was called %d times", server.called)
+	}
+
+	claims := server.claims
+	if claims.Iss != clientEmail || claims.Sub != "" || claims.Aud != server.URL || claims.Exp-claims.Iat != 3600 {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	if claims.Scope != DriveScope+" "+DriveReadOnlyScope {
+		t.Errorf("Unexpected scope: %s", claims.Scope)
+	}
+}
+
+func TestServiceAccountSubject(t *testing.T) {
+	server := newTokenServer(t, 3600, http.StatusOK, "")
+	defer server.Close()
+
+	acc, err := NewServiceAccount(serviceAccountJSON(t, server.URL), WithSubject("dolores@westworld.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		acc     *ServiceAccount
+		subject string
+		token   string
+	}{
+		{acc, "dolores@westworld.com", "token1"},
+		{acc.Impersonate("bernard@westworld.com"), "bernard@westworld.com", "token2"},
+	}
+
+	for _, tc := range tests {
+		token, _, err := tc.acc.AccessToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Every impersonated user has its own access token.
+		if token != tc.token {
+			t.Errorf("%s: unexpected token: %s", tc.subject, token)
+		}
+
+		if server.claims.Sub != tc.subject {
+			t.Errorf("%s: unexpected subject: %s", tc.subject, server.claims.Sub)
+		}
+	}
+}
+
+func TestServiceAccountRefresh(t *testing.T) {
+	// The token expires within the expiryDelta, so every call requests a new one.
+	server := newTokenServer(t, 30, http.StatusOK, "")
+	defer server.Close()
+
+	acc, err := NewServiceAccount(serviceAccountJSON(t, ""), WithTokenURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		token, _, err := acc.AccessToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token != fmt.Sprintf("token%d", i) {
+			t.Errorf("Expected a refreshed token, got: %s", token)
+		}
+	}
+}
+
+func TestServiceAccountConcurrent(t *testing.T) {
+	server := newTokenServer(t, 3600, http.StatusOK, "")
+	defer server.Close()
+
+	acc, err := NewServiceAccount(serviceAccountJSON(t, server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := acc.AccessToken(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if server.called != 1 {
+		t.Errorf("Expected a single token request, got: %d", server.called)
+	}
+}
+
+func TestServiceAccountErrors(t *testing.T) {
+	tests := []struct {
+		status  int
+		errCode string
+		err     error
+	}{
+		{http.StatusBadRequest, "invalid_grant", ErrInvalidGrant},
+		{http.StatusBadRequest, "invalid_scope", bernard.ErrInvalidCredentials},
+		{http.StatusUnauthorized, "unauthorized_client", bernard.ErrInvalidCredentials},
+		{http.StatusInternalServerError, "internal_failure", bernard.ErrNetwork},
+	}
+
+	for _, tc := range tests {
+		server := newTokenServer(t, 3600, tc.status, tc.errCode)
+
+		acc, err := NewServiceAccount(serviceAccountJSON(t, server.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err = acc.AccessToken(); !errors.Is(err, tc.err) {
+			t.Errorf("%d %s: expected %v, got: %v", tc.status, tc.errCode, tc.err, err)
+		}
+
+		server.Close()
+	}
+}
+
+func TestNewServiceAccount(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"invalid JSON", `{`},
+		{"missing email", `{"private_key": "key"}`},
+		{"invalid key", `{"client_email": "bernard", "private_key": "key"}`},
+	}
+
+	for _, tc := range tests {
+		if _, err := NewServiceAccount([]byte(tc.key)); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("%s: expected ErrInvalidKey, got: %v", tc.name, err)
+		}
+	}
+
+	if _, err := NewServiceAccountFromFile("testdata/unknown.json"); err == nil {
+		t.Error("Expected an error for a missing key file")
+	}
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Token is the OAuth 2.0 token of a user.
+// Its JSON encoding is compatible with the token of an rclone remote.
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+// A TokenStore loads and persists the Token of a User.
+type TokenStore interface {
+	Load() (Token, error)
+	Save(Token) error
+}
+
+// TokenFile is a TokenStore which stores the Token as JSON at the file path.
+type TokenFile string
+
+// Load reads the Token from the file.
+func (path TokenFile) Load() (Token, error) {
+	var t Token
+
+	b, err := ioutil.ReadFile(string(path))
+	if err != nil {
+		return t, err
+	}
+
+	if err := json.Unmarshal(b, &t); err != nil {
+		return t, fmt.Errorf("%v: %w", path, err)
+	}
+
+	return t, nil
+}
+
+// Save writes the Token to the file, which can only be read by the owner.
+//
+// The Token is written to a temporary file first,
+// so an interrupted write does not corrupt the existing token.
+func (path TokenFile) Save(t Token) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	tmp := string(path) + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, string(path))
+}
+
+// User is an Authenticator which refreshes the access tokens of a user
+// with the OAuth 2.0 client and the refresh token of the user.
+//
+// The refreshed Token is persisted in the TokenStore.
+type User struct {
+	options
+
+	clientID     string
+	clientSecret string
+	store        TokenStore
+
+	mu    sync.Mutex
+	token Token
+
+	// unsaved is set when the refreshed Token could not be saved,
+	// so the save is retried on the next call.
+	unsaved bool
+}
+
+// NewUser creates a User from the OAuth 2.0 client and the Token loaded from the TokenStore.
+func NewUser(clientID string, clientSecret string, store TokenStore, opts ...Option) (*User, error) {
+	t, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if t.RefreshToken == "" {
+		return nil, fmt.Errorf("refresh token missing: %w", ErrInvalidGrant)
+	}
+
+	return &User{
+		options:      newOptions(GoogleTokenURL, opts),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		store:        store,
+		token:        t,
+	}, nil
+}
+
+// AccessToken returns a cached access token, or refreshes the access token
+// when the cached token is about to expire. The expiry time is returned in UNIX.
+//
+// An error is returned when the refreshed Token cannot be saved.
+// The save is retried on every following call, which keeps returning
+// an error until the Token has been saved.
+func (user *User) AccessToken() (string, int64, error) {
+	user.mu.Lock()
+	defer user.mu.Unlock()
+
+	if user.unsaved {
+		if err := user.save(); err != nil {
+			return "", 0, err
+		}
+	}
+
+	cached := token{accessToken: user.token.AccessToken, expiry: user.token.Expiry}
+	if cached.valid(time.Now()) {
+		return user.token.AccessToken, user.token.Expiry.Unix(), nil
+	}
+
+	t, err := user.requestToken(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {user.token.RefreshToken},
+		"client_id":     {user.clientID},
+		"client_secret": {user.clientSecret},
+	})
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	user.token.AccessToken = t.accessToken
+	user.token.TokenType = "Bearer"
+	user.token.Expiry = t.expiry
+
+	// Google only returns a refresh token when it has been rotated.
+	if t.refreshToken != "" {
+		user.token.RefreshToken = t.refreshToken
+	}
+
+	if err := user.save(); err != nil {
+		return "", 0, err
+	}
+
+	return t.accessToken, t.expiry.Unix(), nil
+}
+
+// save saves the Token in the TokenStore and keeps track of whether it succeeded.
+func (user *User) save() error {
+	if err := user.store.Save(user.token); err != nil {
+		user.unsaved = true
+		return fmt.Errorf("saving token: %w", err)
+	}
+
+	user.unsaved = false
+	return nil
+}
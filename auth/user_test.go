@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m-rots/bernard"
+)
+
+// rcloneToken is a token as stored in the configuration of an rclone remote.
+const rcloneToken = `{"access_token":"expired","token_type":"Bearer","refresh_token":"refresh1","expiry":"2020-05-01T12:00:00.123456+02:00"}`
+
+// tempTokenFile writes the token to a temporary TokenFile.
+func tempTokenFile(t *testing.T, token string) (TokenFile, func()) {
+	dir, err := ioutil.TempDir("", "bernard-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "token.json")
+	if err := ioutil.WriteFile(path, []byte(token), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return TokenFile(path), func() { os.RemoveAll(dir) }
+}
+
+func TestUser(t *testing.T) {
+	var called int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+
+		if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "refresh1" ||
+			r.FormValue("client_id") != "client" || r.FormValue("client_secret") != "secret" {
+			t.Errorf("Unexpected form: %v", r.Form)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token1",
+			"expires_in":   3600,
+		})
+	}))
+
+	defer server.Close()
+
+	store, cleanup := tempTokenFile(t, rcloneToken)
+	defer cleanup()
+
+	user, err := NewUser("client", "secret", store, WithTokenURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var _ bernard.Authenticator = user
+
+	for i := 0; i < 2; i++ {
+		token, exp, err := user.AccessToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token != "token1" || time.Until(time.Unix(exp, 0)) < 59*time.Minute {
+			t.Errorf("Unexpected token: %s, %d", token, exp)
+		}
+	}
+
+	if called != 1 {
+		t.Errorf("Expected the token to be cached, but the server was called %d times", called)
+	}
+
+	// The refreshed token is persisted, keeping the refresh token.
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if saved.AccessToken != "token1" || saved.RefreshToken != "refresh1" || saved.TokenType != "Bearer" {
+		t.Errorf("Unexpected saved token: %+v", saved)
+	}
+}
+
+func TestUserRotatedRefreshToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "token1",
+			"refresh_token": "refresh2",
+			"expires_in":    3600,
+		})
+	}))
+
+	defer server.Close()
+
+	store, cleanup := tempTokenFile(t, rcloneToken)
+	defer cleanup()
+
+	user, err := NewUser("client", "secret", store, WithTokenURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = user.AccessToken(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if saved.RefreshToken != "refresh2" {
+		t.Errorf("Expected the rotated refresh token to be saved, got: %s", saved.RefreshToken)
+	}
+}
+
+// failingStore is a TokenStore of which the first saves fail.
+type failingStore struct {
+	fails int
+	saved []Token
+}
+
+func (store *failingStore) Load() (Token, error) {
+	return Token{RefreshToken: "refresh1"}, nil
+}
+
+func (store *failingStore) Save(t Token) error {
+	if store.fails > 0 {
+		store.fails--
+		return errors.New("disk full")
+	}
+
+	store.saved = append(store.saved, t)
+	return nil
+}
+
+func TestUserSaveRetry(t *testing.T) {
+	var called int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "token1",
+			"refresh_token": "refresh2",
+			"expires_in":    3600,
+		})
+	}))
+
+	defer server.Close()
+
+	store := &failingStore{fails: 2}
+	user, err := NewUser("client", "secret", store, WithTokenURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The save is retried on every call, without refreshing the token again.
+	for i := 0; i < 2; i++ {
+		if _, _, err := user.AccessToken(); err == nil {
+			t.Fatalf("Expected the failed save to be reported at call %d", i+1)
+		}
+	}
+
+	token, _, err := user.AccessToken()
+	if err != nil || token != "token1" {
+		t.Fatalf("Unexpected token: %s, %v", token, err)
+	}
+
+	if len(store.saved) != 1 || store.saved[0].RefreshToken != "refresh2" {
+		t.Errorf("Expected the rotated refresh token to be saved once, got: %+v", store.saved)
+	}
+
+	if _, _, err := user.AccessToken(); err != nil || len(store.saved) != 1 {
+		t.Errorf("A saved token should not be saved again: %v, %+v", err, store.saved)
+	}
+
+	if called != 1 {
+		t.Errorf("Expected a single refresh, got: %d", called)
+	}
+}
+
+func TestUserInvalidGrant(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorResponse{Error: "invalid_grant", Description: "Token has been expired or revoked."})
+	}))
+
+	defer server.Close()
+
+	store, cleanup := tempTokenFile(t, rcloneToken)
+	defer cleanup()
+
+	user, err := NewUser("client", "secret", store, WithTokenURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = user.AccessToken()
+	if !errors.Is(err, ErrInvalidGrant) || !errors.Is(err, bernard.ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidGrant, got: %v", err)
+	}
+
+	// The stored token is left untouched.
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if saved.AccessToken != "expired" {
+		t.Errorf("Unexpected saved token: %+v", saved)
+	}
+}
+
+func TestNewUser(t *testing.T) {
+	store, cleanup := tempTokenFile(t, `{"access_token":"token"}`)
+	defer cleanup()
+
+	if _, err := NewUser("client", "secret", store); !errors.Is(err, ErrInvalidGrant) {
+		t.Errorf("Expected ErrInvalidGrant without a refresh token, got: %v", err)
+	}
+
+	if _, err := NewUser("client", "secret", TokenFile("testdata/unknown.json")); err == nil {
+		t.Error("Expected an error for a missing token file")
+	}
+}
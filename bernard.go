@@ -2,6 +2,7 @@ package bernard
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -13,9 +14,23 @@ type Authenticator interface {
 	AccessToken() (string, int64, error)
 }
 
+// A RateLimitedAuthenticator is an Authenticator which can hand out the access tokens
+// of several accounts, such as a pool of service accounts.
+//
+// When the Drive API rate-limits a request, RateLimited is called with the access token
+// of the request. It reports whether the next call to AccessToken returns the access token
+// of an account which is not rate-limited, in which case the request is retried straight away.
+// Otherwise, or once every account has been rate-limited, the request is retried
+// after an exponential backoff. Every retry counts towards the RetryPolicy.
+type RateLimitedAuthenticator interface {
+	Authenticator
+	RateLimited(accessToken string) bool
+}
+
 // Bernard is a synchronisation backend for Google Drive.
 type Bernard struct {
 	safeSleep time.Duration
+	workers   int
 
 	fetch *fetcher
 	store ds.Datastore
@@ -35,6 +50,9 @@ func WithClient(client *http.Client) Option {
 //
 // This function is called before fetching the authentication token to prevent
 // tokens from expiring when a rate-limit is applied.
+//
+// When a parallel full sync is enabled, the preHook is called concurrently
+// and must therefore be safe for concurrent use.
 func WithPreRequestHook(preHook func()) Option {
 	return func(bernard *Bernard) {
 		bernard.fetch.preHook = preHook
@@ -49,6 +67,26 @@ func WithJSONDecoder(d jsonDecoder) Option {
 	}
 }
 
+// WithFields allows one to request additional properties of every file and folder,
+// such as `imageMediaMetadata(width,height)` or `properties`.
+//
+// The raw JSON of the additional properties is available in the Extra map
+// of the files and folders, keyed on the name of the top-level property.
+// Properties which are already part of the File and Folder structs are ignored.
+func WithFields(fields ...string) Option {
+	return func(bernard *Bernard) {
+		for _, selector := range fields {
+			for _, field := range splitFields(selector) {
+				if knownFields[fieldName(field)] {
+					continue
+				}
+
+				bernard.fetch.fields += "," + field
+			}
+		}
+	}
+}
+
 // WithSafeSleep allows one to sleep between the pageToken fetch and
 // the full sync. Setting this between 1 and 5 minutes prevents
 // any data from going rogue when changes are actively being made
@@ -61,6 +99,31 @@ func WithSafeSleep(duration time.Duration) Option {
 	}
 }
 
+// WithParallelFullSync allows one to fetch the content of a Shared Drive
+// by listing the children of every folder, with up to the provided number of workers
+// listing folders concurrently. This can be considerably faster than the default
+// full sync on Shared Drives with many folders.
+//
+// A parallel full sync keeps all files and folders in memory before handing them
+// to the datastore, even if the datastore implements the StreamingDatastore interface.
+//
+// The parallel full sync is disabled when the number of workers is below 2.
+func WithParallelFullSync(workers int) Option {
+	return func(bernard *Bernard) {
+		bernard.workers = workers
+	}
+}
+
+// WithRetryPolicy overrides the DefaultRetryPolicy, which defines which failed requests
+// are retried and how long to wait before every retry.
+//
+// A request whose retries exceed the budget of the policy fails with ErrRetriesExhausted.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(bernard *Bernard) {
+		bernard.fetch.retry = policy
+	}
+}
+
 // New creates a new instance of Bernard
 func New(auth Authenticator, store ds.Datastore, opts ...Option) *Bernard {
 	const baseURL string = "https://www.googleapis.com/drive/v3"
@@ -72,7 +135,9 @@ func New(auth Authenticator, store ds.Datastore, opts ...Option) *Bernard {
 			Timeout: 15 * time.Second,
 		},
 		decodeJSON: decodeJSON,
-		sleep:      time.Sleep,
+		sleep:      sleepContext,
+		retry:      DefaultRetryPolicy,
+		fields:     itemFields,
 	}
 
 	bernard := &Bernard{
@@ -96,6 +161,28 @@ var ErrInvalidCredentials = errors.New("bernard: invalid credentials")
 // Shared Drive or if the Shared Drive does not exist.
 var ErrNotFound = errors.New("bernard: cannot find Shared Drive")
 
-// ErrNetwork is the result of a networking error while contacting the Google Drive API.
-// This error is only thrown on status codes not equal to 200, 401 and 500.
+// ErrNetwork is the result of a networking error while contacting the Google Drive API,
+// or of an error response which is neither retryable according to the RetryPolicy,
+// nor a 401 or 404 status code.
 var ErrNetwork = errors.New("bernard: network related error")
+
+// ErrRetriesExhausted occurs when a request keeps failing with retryable errors
+// until the budget of the RetryPolicy runs out.
+var ErrRetriesExhausted = errors.New("bernard: retries exhausted")
+
+// A ParentlessError occurs when Google Drive returns a file or folder without any parents,
+// for example when the visibility of the item is restricted.
+//
+// A ParentlessError is a data anomaly.
+type ParentlessError struct {
+	ID string
+}
+
+func (e *ParentlessError) Error() string {
+	return fmt.Sprintf("bernard: %s does not have any parents", e.ID)
+}
+
+// Unwrap allows errors.Is to match a ParentlessError with ds.ErrDataAnomaly.
+func (e *ParentlessError) Unwrap() error {
+	return ds.ErrDataAnomaly
+}
@@ -47,7 +47,9 @@ func (store *Devstore) CreateSnapshot(driveID string) (*Snapshot, error) {
 	defer fileRows.Close()
 	for fileRows.Next() {
 		f := ds.File{}
-		err = fileRows.Scan(&f.ID, &f.Name, &f.Parent, &f.Size, &f.MD5, &f.Trashed)
+		err = fileRows.Scan(&f.ID, &f.Name, &f.Parent, &f.Size, &f.MD5, &f.Trashed,
+			&f.SHA1, &f.SHA256, &f.MimeType, &f.CreatedTime, &f.ModifiedTime, &f.Version,
+			&f.FileExtension, &f.OriginalFilename, &f.HeadRevisionID)
 		if err != nil {
 			return nil, err
 		}
@@ -87,6 +89,34 @@ func (store *Devstore) CreateSnapshot(driveID string) (*Snapshot, error) {
 	return ss, nil
 }
 
+// NewSnapshot creates a *Snapshot of the provided folders and files,
+// which should already be sorted on ID. Like CreateSnapshot, the root folder is left out.
+//
+// Only the fields which are part of a Snapshot created by the Devstore are copied,
+// so the Snapshot of another datastore can be compared to the Snapshot of the Devstore.
+func NewSnapshot(folders []ds.Folder, files []ds.File) *Snapshot {
+	ss := new(Snapshot)
+
+	for _, f := range folders {
+		if f.Parent == "" {
+			continue
+		}
+
+		ss.Folders = append(ss.Folders, ds.Folder{ID: f.ID, Name: f.Name, Parent: f.Parent, Trashed: f.Trashed})
+	}
+
+	for _, f := range files {
+		ss.Files = append(ss.Files, ds.File{
+			ID: f.ID, Name: f.Name, Parent: f.Parent, Size: f.Size, MD5: f.MD5, Trashed: f.Trashed,
+			SHA1: f.SHA1, SHA256: f.SHA256, MimeType: f.MimeType, CreatedTime: f.CreatedTime,
+			ModifiedTime: f.ModifiedTime, Version: f.Version, FileExtension: f.FileExtension,
+			OriginalFilename: f.OriginalFilename, HeadRevisionID: f.HeadRevisionID,
+		})
+	}
+
+	return ss
+}
+
 const sqlSelectFolders = `
 SELECT id, name, trashed, parent
 FROM folder
@@ -95,7 +125,9 @@ ORDER BY id ASC
 `
 
 const sqlSelectFiles = `
-SELECT id, name, parent, size, md5, trashed
+SELECT id, name, parent, size, md5, trashed,
+	sha1, sha256, mimeType, createdTime, modifiedTime, version,
+	fileExtension, originalFilename, headRevisionId
 FROM file
 WHERE drive=?
 ORDER BY id ASC
@@ -1,48 +1,19 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"reflect"
 
 	lowe "github.com/m-rots/bernard"
+	"github.com/m-rots/bernard/auth"
 	"github.com/m-rots/bernard/cmd/bernard/devstore"
 	ds "github.com/m-rots/bernard/datastore"
+	"github.com/m-rots/bernard/datastore/memory"
 	"github.com/m-rots/bernard/datastore/sqlite"
-	"github.com/m-rots/stubbs"
 )
 
-type googleServiceAccount struct {
-	Email      string `json:"client_email"`
-	PrivateKey string `json:"private_key"`
-}
-
-func getStubbs(path string, scopes []string) *stubbs.Stubbs {
-	file, err := os.Open(path)
-	if err != nil {
-		fmt.Println("Could not open service account")
-		os.Exit(1)
-	}
-
-	decoder := json.NewDecoder(file)
-	sa := new(googleServiceAccount)
-
-	if decoder.Decode(sa) != nil {
-		fmt.Println("Error decoding service account")
-		os.Exit(1)
-	}
-
-	priv, err := stubbs.ParseKey(sa.PrivateKey)
-	if err != nil {
-		fmt.Println("Invalid private key")
-		os.Exit(1)
-	}
-
-	return stubbs.New(sa.Email, &priv, scopes, 3600)
-}
-
 const (
 	colourReset   string = "\u001b[0m"
 	colourRed     string = "\u001b[31;1m"
@@ -54,8 +25,8 @@ const (
 func main() {
 	args := os.Args[1:]
 
-	if len(args) != 3 {
-		fmt.Println("1st arg: full or diff, 2nd arg: driveID, 3rd arg: path to sa")
+	if len(args) != 3 && len(args) != 4 {
+		fmt.Println("1st arg: full or diff, 2nd arg: driveID, 3rd arg: path to sa, optional 4th arg: user to impersonate")
 		os.Exit(1)
 	}
 
@@ -63,6 +34,11 @@ func main() {
 	driveID := args[1]
 	saPath := args[2]
 
+	var subject string
+	if len(args) == 4 {
+		subject = args[3]
+	}
+
 	switch args[0] {
 	case "full":
 		fullSync = true
@@ -78,12 +54,18 @@ func main() {
 		panic(err)
 	}
 
-	auth := getStubbs(saPath, []string{
-		"https://www.googleapis.com/auth/drive",
-		"https://www.googleapis.com/auth/iam",
-	})
+	// Domain-wide delegation only grants the scopes allow-listed by the admin,
+	// so only the Drive scope is requested.
+	account, err := auth.NewServiceAccountFromFile(saPath,
+		auth.WithScopes(auth.DriveScope),
+		auth.WithSubject(subject))
+
+	if err != nil {
+		fmt.Println("Could not load service account:", err)
+		os.Exit(1)
+	}
 
-	bernard := lowe.New(auth, store)
+	bernard := lowe.New(account, store)
 
 	if fullSync {
 		fmt.Printf("%slog%s - Starting full sync for the first time\n", colourMagenta, colourReset)
@@ -135,13 +117,10 @@ func main() {
 		fmt.Printf("%slog%s - Comparing local datastore against full sync as there are less than 10.000 files\n", colourMagenta, colourReset)
 
 		fmt.Printf("%slog%s - Creating in-memory datastore\n", colourMagenta, colourReset)
-		memStore, err := devstore.New("file:screenshot?mode=memory")
-		if err != nil {
-			panic(err) // no error should occur here
-		}
+		memStore := memory.New()
 
 		fmt.Printf("%slog%s - Running full sync to act as reference state\n", colourMagenta, colourReset)
-		reference := lowe.New(auth, memStore)
+		reference := lowe.New(account, memStore)
 		err = reference.FullSync(driveID)
 		if err != nil {
 			if errors.Is(err, ds.ErrDataAnomaly) {
@@ -155,10 +134,7 @@ func main() {
 		}
 
 		fmt.Printf("%slog%s - Creating reference snapshot\n", colourMagenta, colourReset)
-		referenceState, err := memStore.CreateSnapshot(driveID)
-		if err != nil {
-			panic(err) // no error should occur here
-		}
+		referenceState := devstore.NewSnapshot(memStore.Folders(driveID), memStore.Files(driveID))
 
 		if reflect.DeepEqual(newState, referenceState) {
 			fmt.Printf("\n%slog%s - Local and remote states are equal\n", colourMagenta, colourReset)
@@ -198,7 +174,7 @@ func printDifference(diff *sqlite.Difference) {
 	if len(diff.ChangedFolders) > 0 {
 		fmt.Println("\nChanged folders:")
 		for _, d := range diff.ChangedFolders {
-			fmt.Printf("%schanged%s - %s - %s\n", colourYellow, colourReset, d.New.ID, d.New.Name)
+			fmt.Printf("%schanged%s - %s - %s (%s)\n", colourYellow, colourReset, d.New.ID, d.New.Name, d.Kind)
 		}
 	}
 
@@ -206,7 +182,7 @@ func printDifference(diff *sqlite.Difference) {
 	if len(diff.ChangedFiles) > 0 {
 		fmt.Println("\nChanged files:")
 		for _, d := range diff.ChangedFiles {
-			fmt.Printf("%schanged%s - %s - %s\n", colourYellow, colourReset, d.New.ID, d.New.Name)
+			fmt.Printf("%schanged%s - %s - %s (%s)\n", colourYellow, colourReset, d.New.ID, d.New.Name, d.Kind)
 		}
 	}
 
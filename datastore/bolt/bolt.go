@@ -0,0 +1,527 @@
+// Package bolt provides a Bernard datastore on top of bbolt, an embedded key-value store.
+//
+// Unlike the reference SQLite datastore, this datastore is written in pure Go.
+// Therefore, Bernard can be compiled with `CGO_ENABLED=0` into a static binary.
+//
+// Every Shared Drive gets its own bucket, which holds the pageToken,
+// the folders and files encoded as JSON, and two indexes:
+// one of the children of every folder and one of the items
+// linked to a folder as an additional parent.
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	ds "github.com/m-rots/bernard/datastore"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// New returns a Bernard Datastore with a bbolt backend.
+//
+// The database file is created when it does not exist yet.
+func New(path string) (*Datastore, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", ds.ErrDatabase)
+	}
+
+	return FromDB(db)
+}
+
+// FromDB returns a Bernard Datastore with the given bbolt backend.
+func FromDB(db *bbolt.DB) (*Datastore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketDrives)
+		return err
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("buckets: %w", ds.ErrDatabase)
+	}
+
+	return &Datastore{DB: db}, nil
+}
+
+// Datastore holds our bbolt database
+// and implements the Bernard Datastore interface.
+type Datastore struct {
+	DB *bbolt.DB
+}
+
+var (
+	bucketDrives   = []byte("drives")
+	bucketFolders  = []byte("folders")
+	bucketFiles    = []byte("files")
+	bucketChildren = []byte("children")
+	bucketLinks    = []byte("links")
+	keyPageToken   = []byte("pageToken")
+)
+
+// The kind of item an index entry points to.
+var (
+	kindFolder = []byte{'d'}
+	kindFile   = []byte{'f'}
+)
+
+// indexKey returns the key of an index entry of a child within a parent.
+// The IDs of Google Drive never contain a NUL byte.
+func indexKey(parent, child string) []byte {
+	return []byte(parent + "\x00" + child)
+}
+
+// FullSync synchronises the provided Drive state to the datastore.
+func (store *Datastore) FullSync(drive ds.Drive, folders []ds.Folder, files []ds.File) error {
+	return store.FullSyncContext(context.Background(), drive, folders, files)
+}
+
+// FullSyncContext synchronises the provided Drive state to the datastore.
+//
+// The relationship constraints are checked once all folders and files are written.
+// The transaction is rolled back when the context is cancelled.
+func (store *Datastore) FullSyncContext(ctx context.Context, drive ds.Drive, folders []ds.Folder, files []ds.File) error {
+	// Insert the Shared Drive as the root folder.
+	root := ds.Folder{ID: drive.ID, Name: drive.Name}
+	return store.write(ctx, drive, append([]ds.Folder{root}, folders...), files, nil)
+}
+
+// PartialSync synchronises the provided changes to the datastore.
+func (store *Datastore) PartialSync(drive ds.Drive, changedFolders []ds.Folder, changedFiles []ds.File, removedIDs []string) error {
+	return store.PartialSyncContext(context.Background(), drive, changedFolders, changedFiles, removedIDs)
+}
+
+// PartialSyncContext synchronises the provided changes to the datastore.
+//
+// 1. Update the pageToken and (if applicable) the name of the Shared Drive.
+//
+// 2. Upsert the changed folders and files.
+//
+// 3. Remove any items of which the IDs match with the removedIDs slice.
+//
+// Like the reference SQLite datastore, removing a folder which still has children
+// results in a data anomaly, while it is removed from the additional parents of other items.
+// The transaction is rolled back when the context is cancelled.
+func (store *Datastore) PartialSyncContext(ctx context.Context, drive ds.Drive, changedFolders []ds.Folder, changedFiles []ds.File, removedIDs []string) error {
+	// Drive name is empty if not changed, so when not empty we should update it.
+	if drive.Name != "" {
+		root := ds.Folder{ID: drive.ID, Name: drive.Name}
+		changedFolders = append([]ds.Folder{root}, changedFolders...)
+	}
+
+	return store.write(ctx, drive, changedFolders, changedFiles, removedIDs)
+}
+
+// write upserts the folders and files, removes the removedIDs
+// and saves the pageToken within a single transaction.
+func (store *Datastore) write(ctx context.Context, drive ds.Drive, folders []ds.Folder, files []ds.File, removedIDs []string) error {
+	err := store.DB.Update(func(tx *bbolt.Tx) error {
+		d, err := createDrive(tx, drive.ID)
+		if err != nil {
+			return err
+		}
+
+		// Update the pageToken for future sync jobs.
+		if err := d.bucket.Put(keyPageToken, []byte(drive.PageToken)); err != nil {
+			return fmt.Errorf("pageToken: %w", ds.ErrDatabase)
+		}
+
+		for _, f := range folders {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if err := d.putFolder(f); err != nil {
+				return err
+			}
+		}
+
+		for _, f := range files {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if err := d.putFile(f); err != nil {
+				return err
+			}
+		}
+
+		for _, id := range removedIDs {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if err := d.remove(id); err != nil {
+				return err
+			}
+		}
+
+		return d.check()
+	})
+
+	// Any error returned within the transaction rolls back the transaction.
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// PageToken retrieves the pageToken the datastore currently reflects.
+func (store *Datastore) PageToken(driveID string) (string, error) {
+	return store.PageTokenContext(context.Background(), driveID)
+}
+
+// PageTokenContext retrieves the pageToken the datastore currently reflects.
+func (store *Datastore) PageTokenContext(ctx context.Context, driveID string) (pageToken string, err error) {
+	err = store.DB.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(bucketDrives).Bucket([]byte(driveID))
+		if bucket == nil {
+			return ds.ErrFullSync
+		}
+
+		pageToken = string(bucket.Get(keyPageToken))
+		return nil
+	})
+
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	return pageToken, err
+}
+
+// driveTx provides access to the buckets of a single Shared Drive within a transaction.
+type driveTx struct {
+	bucket   *bbolt.Bucket
+	folders  *bbolt.Bucket
+	files    *bbolt.Bucket
+	children *bbolt.Bucket
+	links    *bbolt.Bucket
+
+	// written holds the IDs of all upserted items,
+	// of which the parents are checked before the transaction is committed.
+	written map[string]struct{}
+
+	// removed holds the IDs of all removed folders,
+	// which must not have any children left when the transaction is committed.
+	removed map[string]struct{}
+}
+
+// createDrive returns the buckets of the Shared Drive and creates them when they do not exist yet.
+func createDrive(tx *bbolt.Tx, driveID string) (*driveTx, error) {
+	bucket, err := tx.Bucket(bucketDrives).CreateBucketIfNotExists([]byte(driveID))
+	if err != nil {
+		return nil, fmt.Errorf("%v bucket: %w", driveID, ds.ErrDatabase)
+	}
+
+	d := &driveTx{bucket: bucket, written: make(map[string]struct{}), removed: make(map[string]struct{})}
+
+	for _, b := range []struct {
+		bucket **bbolt.Bucket
+		name   []byte
+	}{
+		{&d.folders, bucketFolders},
+		{&d.files, bucketFiles},
+		{&d.children, bucketChildren},
+		{&d.links, bucketLinks},
+	} {
+		*b.bucket, err = bucket.CreateBucketIfNotExists(b.name)
+		if err != nil {
+			return nil, fmt.Errorf("%s bucket: %w", b.name, ds.ErrDatabase)
+		}
+	}
+
+	return d, nil
+}
+
+// openDrive returns the buckets of an existing Shared Drive for read-only access.
+// Nil is returned when the Shared Drive does not exist.
+func openDrive(tx *bbolt.Tx, driveID string) *driveTx {
+	bucket := tx.Bucket(bucketDrives).Bucket([]byte(driveID))
+	if bucket == nil {
+		return nil
+	}
+
+	return &driveTx{
+		bucket:   bucket,
+		folders:  bucket.Bucket(bucketFolders),
+		files:    bucket.Bucket(bucketFiles),
+		children: bucket.Bucket(bucketChildren),
+		links:    bucket.Bucket(bucketLinks),
+	}
+}
+
+// folder returns the folder with the provided ID, or nil when it does not exist.
+func (d *driveTx) folder(id string) (*ds.Folder, error) {
+	value := d.folders.Get([]byte(id))
+	if value == nil {
+		return nil, nil
+	}
+
+	f := new(ds.Folder)
+	if err := json.Unmarshal(value, f); err != nil {
+		return nil, fmt.Errorf("%v decode: %w", id, ds.ErrDatabase)
+	}
+
+	return f, nil
+}
+
+// file returns the file with the provided ID, or nil when it does not exist.
+func (d *driveTx) file(id string) (*ds.File, error) {
+	value := d.files.Get([]byte(id))
+	if value == nil {
+		return nil, nil
+	}
+
+	f := new(ds.File)
+	if err := json.Unmarshal(value, f); err != nil {
+		return nil, fmt.Errorf("%v decode: %w", id, ds.ErrDatabase)
+	}
+
+	return f, nil
+}
+
+// putFolder upserts the folder and moves its index entries to the new parents.
+func (d *driveTx) putFolder(f ds.Folder) error {
+	old, err := d.folder(f.ID)
+	if err != nil {
+		return err
+	}
+
+	if old != nil {
+		if err := d.unindex(f.ID, old.Parent, old.AdditionalParents); err != nil {
+			return err
+		}
+	}
+
+	value, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("%v encode: %w", f.ID, ds.ErrDatabase)
+	}
+
+	if err := d.folders.Put([]byte(f.ID), value); err != nil {
+		return fmt.Errorf("%v: %w", f.ID, ds.ErrDatabase)
+	}
+
+	d.written[f.ID] = struct{}{}
+	return d.index(f.ID, kindFolder, f.Parent, f.AdditionalParents)
+}
+
+// putFile upserts the file and moves its index entries to the new parents.
+func (d *driveTx) putFile(f ds.File) error {
+	old, err := d.file(f.ID)
+	if err != nil {
+		return err
+	}
+
+	if old != nil {
+		if err := d.unindex(f.ID, old.Parent, old.AdditionalParents); err != nil {
+			return err
+		}
+	}
+
+	value, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("%v encode: %w", f.ID, ds.ErrDatabase)
+	}
+
+	if err := d.files.Put([]byte(f.ID), value); err != nil {
+		return fmt.Errorf("%v: %w", f.ID, ds.ErrDatabase)
+	}
+
+	d.written[f.ID] = struct{}{}
+	return d.index(f.ID, kindFile, f.Parent, f.AdditionalParents)
+}
+
+// index adds the item to the children of its parent and the links of its additional parents.
+// The root folder does not have a parent.
+func (d *driveTx) index(id string, kind []byte, parent string, additionalParents []string) error {
+	if parent != "" {
+		if err := d.children.Put(indexKey(parent, id), kind); err != nil {
+			return fmt.Errorf("%v index: %w", id, ds.ErrDatabase)
+		}
+	}
+
+	for _, p := range additionalParents {
+		if err := d.links.Put(indexKey(p, id), kind); err != nil {
+			return fmt.Errorf("%v index: %w", id, ds.ErrDatabase)
+		}
+	}
+
+	return nil
+}
+
+// unindex removes the index entries added by index.
+func (d *driveTx) unindex(id string, parent string, additionalParents []string) error {
+	if parent != "" {
+		if err := d.children.Delete(indexKey(parent, id)); err != nil {
+			return fmt.Errorf("%v index: %w", id, ds.ErrDatabase)
+		}
+	}
+
+	for _, p := range additionalParents {
+		if err := d.links.Delete(indexKey(p, id)); err != nil {
+			return fmt.Errorf("%v index: %w", id, ds.ErrDatabase)
+		}
+	}
+
+	return nil
+}
+
+// indexEntry is a child within the children or links index.
+type indexEntry struct {
+	id       string
+	isFolder bool
+}
+
+// entries returns all children of the parent within the provided index.
+func entries(index *bbolt.Bucket, parent string) (children []indexEntry) {
+	prefix := indexKey(parent, "")
+
+	c := index.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		children = append(children, indexEntry{
+			id:       string(k[len(prefix):]),
+			isFolder: bytes.Equal(v, kindFolder),
+		})
+	}
+
+	return children
+}
+
+// remove deletes the file or folder with the provided ID.
+// Removing an item which does not exist is a no-op.
+func (d *driveTx) remove(id string) error {
+	file, err := d.file(id)
+	if err != nil {
+		return err
+	}
+
+	if file != nil {
+		return d.removeFile(*file)
+	}
+
+	folder, err := d.folder(id)
+	if err != nil {
+		return err
+	}
+
+	if folder != nil {
+		return d.removeFolder(*folder)
+	}
+
+	return nil
+}
+
+// removeFile deletes the file and its index entries.
+func (d *driveTx) removeFile(f ds.File) error {
+	if err := d.unindex(f.ID, f.Parent, f.AdditionalParents); err != nil {
+		return err
+	}
+
+	if err := d.files.Delete([]byte(f.ID)); err != nil {
+		return fmt.Errorf("%v: %w", f.ID, ds.ErrDatabase)
+	}
+
+	return nil
+}
+
+// removeFolder deletes the folder and its index entries.
+// The folder is removed from the additional parents of any linked items.
+//
+// The children of the folder are left untouched, as they may still be moved or removed
+// later on in the transaction. Any remaining children are reported by check.
+func (d *driveTx) removeFolder(f ds.Folder) error {
+	d.removed[f.ID] = struct{}{}
+
+	// The entries are collected first, as a bucket must not be modified while iterating.
+	for _, link := range entries(d.links, f.ID) {
+		if err := d.unlink(link, f.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := d.unindex(f.ID, f.Parent, f.AdditionalParents); err != nil {
+		return err
+	}
+
+	if err := d.folders.Delete([]byte(f.ID)); err != nil {
+		return fmt.Errorf("%v: %w", f.ID, ds.ErrDatabase)
+	}
+
+	return nil
+}
+
+// unlink removes the parent from the additional parents of the linked item.
+func (d *driveTx) unlink(link indexEntry, parent string) error {
+	if link.isFolder {
+		f, err := d.folder(link.id)
+		if err != nil || f == nil {
+			return err
+		}
+
+		f.AdditionalParents = without(f.AdditionalParents, parent)
+		return d.putFolder(*f)
+	}
+
+	f, err := d.file(link.id)
+	if err != nil || f == nil {
+		return err
+	}
+
+	f.AdditionalParents = without(f.AdditionalParents, parent)
+	return d.putFile(*f)
+}
+
+// without returns the IDs without the provided ID, or nil when no IDs remain.
+func without(ids []string, id string) (remaining []string) {
+	for _, i := range ids {
+		if i != id {
+			remaining = append(remaining, i)
+		}
+	}
+
+	return remaining
+}
+
+// check returns ErrDataAnomaly when the parent or any of the additional parents
+// of an upserted item does not exist, or when a removed folder still has children.
+func (d *driveTx) check() error {
+	for id := range d.removed {
+		if children := entries(d.children, id); len(children) > 0 {
+			return fmt.Errorf("%v parent %v: %w", children[0].id, id, ds.ErrDataAnomaly)
+		}
+	}
+
+	for id := range d.written {
+		var parent string
+		var additionalParents []string
+
+		if f, err := d.folder(id); err != nil {
+			return err
+		} else if f != nil {
+			parent, additionalParents = f.Parent, f.AdditionalParents
+		}
+
+		if f, err := d.file(id); err != nil {
+			return err
+		} else if f != nil {
+			parent, additionalParents = f.Parent, f.AdditionalParents
+		}
+
+		if parent != "" && d.folders.Get([]byte(parent)) == nil {
+			return fmt.Errorf("%v parent %v: %w", id, parent, ds.ErrDataAnomaly)
+		}
+
+		for _, p := range additionalParents {
+			if d.folders.Get([]byte(p)) == nil {
+				return fmt.Errorf("%v parent %v: %w", id, p, ds.ErrDataAnomaly)
+			}
+		}
+	}
+
+	return nil
+}
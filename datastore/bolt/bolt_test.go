@@ -0,0 +1,410 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	ds "github.com/m-rots/bernard/datastore"
+	bbolt "go.etcd.io/bbolt"
+)
+
+func setupTest(t *testing.T) *Datastore {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "bernard")
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+
+	datastore, err := New(filepath.Join(dir, "bernard.db"))
+	if err != nil {
+		t.Fatal("Could not create datastore")
+	}
+
+	t.Cleanup(func() {
+		datastore.DB.Close()
+		os.RemoveAll(dir)
+	})
+
+	return datastore
+}
+
+// getItems returns all folders and files of the Shared Drive, ordered on ID.
+func getItems(t *testing.T, store *Datastore, driveID string) (folders []ds.Folder, files []ds.File) {
+	t.Helper()
+
+	err := store.DB.View(func(tx *bbolt.Tx) error {
+		d := openDrive(tx, driveID)
+		if d == nil {
+			return nil
+		}
+
+		err := d.folders.ForEach(func(_, value []byte) error {
+			var f ds.Folder
+			if err := json.Unmarshal(value, &f); err != nil {
+				return err
+			}
+
+			folders = append(folders, f)
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return d.files.ForEach(func(_, value []byte) error {
+			var f ds.File
+			if err := json.Unmarshal(value, &f); err != nil {
+				return err
+			}
+
+			files = append(files, f)
+			return nil
+		})
+	})
+
+	if err != nil {
+		t.Fatalf("Could not read items: %s", err.Error())
+	}
+
+	return folders, files
+}
+
+// getIndex returns all parent-child pairs of the index bucket.
+func getIndex(t *testing.T, store *Datastore, driveID string, name []byte) (pairs []string) {
+	t.Helper()
+
+	store.DB.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketDrives).Bucket([]byte(driveID)).Bucket(name).ForEach(func(key, _ []byte) error {
+			pairs = append(pairs, string(key))
+			return nil
+		})
+	})
+
+	return pairs
+}
+
+func TestPageToken(t *testing.T) {
+	store := setupTest(t)
+
+	_, err := store.PageToken("drive")
+	if !errors.Is(err, ds.ErrFullSync) {
+		t.Errorf("Expected ErrFullSync, got: %v", err)
+	}
+
+	err = store.FullSync(ds.Drive{ID: "drive", Name: "Shared Drive", PageToken: "123"}, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error at full sync: %s", err.Error())
+	}
+
+	pageToken, err := store.PageToken("drive")
+	if err != nil {
+		t.Fatalf("Could not get pageToken: %s", err.Error())
+	}
+
+	if pageToken != "123" {
+		t.Errorf("PageTokens do not match")
+	}
+}
+
+func TestFullSync(t *testing.T) {
+	type Given struct {
+		folders []ds.Folder
+		files   []ds.File
+	}
+
+	type Expected struct {
+		err     error
+		folders []ds.Folder
+		files   []ds.File
+	}
+
+	type test struct {
+		name     string
+		given    Given
+		expected Expected
+	}
+
+	drive := ds.Drive{ID: "drive", Name: "Shared Drive", PageToken: "1"}
+	driveFolder := ds.Folder{ID: drive.ID, Name: drive.Name}
+	modified := time.Date(2020, 3, 2, 12, 30, 15, 123000000, time.UTC)
+
+	var testCases = []test{
+		{
+			name: "invalid file parent -> data anomaly",
+			given: Given{
+				files: []ds.File{{ID: "Z", Parent: "unknown"}},
+			},
+			expected: Expected{err: ds.ErrDataAnomaly},
+		},
+		{
+			name: "invalid folder parent -> data anomaly",
+			given: Given{
+				folders: []ds.Folder{{ID: "A", Parent: "unknown"}},
+			},
+			expected: Expected{err: ds.ErrDataAnomaly},
+		},
+		{
+			name: "invalid additional parent -> data anomaly",
+			given: Given{
+				files: []ds.File{{ID: "Z", Parent: "drive", AdditionalParents: []string{"unknown"}}},
+			},
+			expected: Expected{err: ds.ErrDataAnomaly},
+		},
+		{
+			name: "children before their parents",
+			given: Given{
+				folders: []ds.Folder{
+					{ID: "B", Name: "Folder B", Parent: "A"},
+					{ID: "A", Name: "Folder A", Parent: "drive", Extra: map[string]json.RawMessage{"properties": json.RawMessage(`{"a":1}`)}},
+				},
+				files: []ds.File{
+					{ID: "Z", Name: "File Z", Parent: "B", AdditionalParents: []string{"A"}, MD5: "ZZZ", Size: 10,
+						MimeType: "image/png", CreatedTime: modified, ModifiedTime: modified, Version: 2},
+					{ID: "Y", Name: "To Folder A", Parent: "drive", MimeType: "application/vnd.google-apps.shortcut",
+						Shortcut: &ds.Shortcut{TargetID: "A", TargetMimeType: "application/vnd.google-apps.folder"}},
+				},
+			},
+			expected: Expected{
+				folders: []ds.Folder{
+					{ID: "A", Name: "Folder A", Parent: "drive", Extra: map[string]json.RawMessage{"properties": json.RawMessage(`{"a":1}`)}},
+					{ID: "B", Name: "Folder B", Parent: "A"},
+					driveFolder,
+				},
+				files: []ds.File{
+					{ID: "Y", Name: "To Folder A", Parent: "drive", MimeType: "application/vnd.google-apps.shortcut",
+						Shortcut: &ds.Shortcut{TargetID: "A", TargetMimeType: "application/vnd.google-apps.folder"}},
+					{ID: "Z", Name: "File Z", Parent: "B", AdditionalParents: []string{"A"}, MD5: "ZZZ", Size: 10,
+						MimeType: "image/png", CreatedTime: modified, ModifiedTime: modified, Version: 2},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := setupTest(t)
+
+			err := store.FullSync(drive, tc.given.folders, tc.given.files)
+			if !errors.Is(err, tc.expected.err) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			folders, files := getItems(t, store, drive.ID)
+
+			if !reflect.DeepEqual(folders, tc.expected.folders) {
+				t.Log(folders)
+				t.Log(tc.expected.folders)
+				t.Error("Folders do not match")
+			}
+
+			if !reflect.DeepEqual(files, tc.expected.files) {
+				t.Log(files)
+				t.Log(tc.expected.files)
+				t.Error("Files do not match")
+			}
+
+			// A rolled back full sync should not leave a pageToken behind.
+			if tc.expected.err != nil {
+				if _, err := store.PageToken(drive.ID); !errors.Is(err, ds.ErrFullSync) {
+					t.Errorf("Expected ErrFullSync, got: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestPartialSync(t *testing.T) {
+	drive := ds.Drive{ID: "drive", Name: "Shared Drive", PageToken: "1"}
+	driveFolder := ds.Folder{ID: drive.ID, Name: drive.Name}
+
+	type Changes struct {
+		drive   ds.Drive
+		folders []ds.Folder
+		files   []ds.File
+		removed []string
+	}
+
+	type Expected struct {
+		err      error
+		folders  []ds.Folder
+		files    []ds.File
+		children []string
+		links    []string
+	}
+
+	type test struct {
+		name     string
+		changes  Changes
+		expected Expected
+	}
+
+	var testCases = []test{
+		{
+			name: "move and rename",
+			changes: Changes{
+				drive:   ds.Drive{ID: drive.ID, Name: "Renamed", PageToken: "2"},
+				folders: []ds.Folder{{ID: "B", Name: "Folder B", Parent: "drive"}},
+				files:   []ds.File{{ID: "Z", Name: "File Z", Parent: "A"}},
+			},
+			expected: Expected{
+				folders: []ds.Folder{
+					{ID: "A", Name: "Folder A", Parent: "drive"},
+					{ID: "B", Name: "Folder B", Parent: "drive"},
+					{ID: "C", Name: "Folder C", Parent: "drive"},
+					{ID: "drive", Name: "Renamed"},
+				},
+				files: []ds.File{
+					{ID: "X", Name: "File X", Parent: "C", AdditionalParents: []string{"B"}},
+					{ID: "Y", Name: "File Y", Parent: "B"},
+					{ID: "Z", Name: "File Z", Parent: "A"},
+				},
+				children: []string{"A\x00Z", "B\x00Y", "C\x00X", "drive\x00A", "drive\x00B", "drive\x00C"},
+				links:    []string{"B\x00X"},
+			},
+		},
+		{
+			name: "remove folder with all its children",
+			changes: Changes{
+				drive:   ds.Drive{ID: drive.ID, PageToken: "2"},
+				removed: []string{"A", "B", "Y"},
+			},
+			expected: Expected{
+				folders: []ds.Folder{
+					{ID: "C", Name: "Folder C", Parent: "drive"},
+					driveFolder,
+				},
+				files: []ds.File{
+					{ID: "X", Name: "File X", Parent: "C"},
+					{ID: "Z", Name: "File Z", Parent: "drive"},
+				},
+				children: []string{"C\x00X", "drive\x00C", "drive\x00Z"},
+			},
+		},
+		{
+			name: "moved out of a removed folder",
+			changes: Changes{
+				drive: ds.Drive{ID: drive.ID, PageToken: "2"},
+				files: []ds.File{
+					{ID: "Y", Name: "File Y", Parent: "drive"},
+				},
+				removed: []string{"A", "B", "Z"},
+			},
+			expected: Expected{
+				folders: []ds.Folder{
+					{ID: "C", Name: "Folder C", Parent: "drive"},
+					driveFolder,
+				},
+				files: []ds.File{
+					{ID: "X", Name: "File X", Parent: "C"},
+					{ID: "Y", Name: "File Y", Parent: "drive"},
+				},
+				children: []string{"C\x00X", "drive\x00C", "drive\x00Y"},
+			},
+		},
+		{
+			name: "remove folder which still has children -> data anomaly",
+			changes: Changes{
+				drive:   ds.Drive{ID: drive.ID, PageToken: "2"},
+				removed: []string{"A"},
+			},
+			expected: Expected{err: ds.ErrDataAnomaly},
+		},
+		{
+			name: "moved into an unknown folder -> data anomaly",
+			changes: Changes{
+				drive: ds.Drive{ID: drive.ID, PageToken: "2"},
+				files: []ds.File{{ID: "Z", Name: "File Z", Parent: "unknown"}},
+			},
+			expected: Expected{err: ds.ErrDataAnomaly},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := setupTest(t)
+
+			err := store.FullSync(drive,
+				[]ds.Folder{
+					{ID: "A", Name: "Folder A", Parent: "drive"},
+					{ID: "B", Name: "Folder B", Parent: "A"},
+					{ID: "C", Name: "Folder C", Parent: "drive"},
+				},
+				[]ds.File{
+					{ID: "Z", Name: "File Z", Parent: "drive"},
+					{ID: "Y", Name: "File Y", Parent: "B"},
+					{ID: "X", Name: "File X", Parent: "C", AdditionalParents: []string{"B"}},
+				},
+			)
+
+			if err != nil {
+				t.Fatalf("Unexpected error at full sync: %s", err.Error())
+			}
+
+			err = store.PartialSync(tc.changes.drive, tc.changes.folders, tc.changes.files, tc.changes.removed)
+			if !errors.Is(err, tc.expected.err) {
+				t.Fatalf("Unexpected error at partial sync: %v", err)
+			}
+
+			pageToken, _ := store.PageToken(drive.ID)
+
+			if tc.expected.err != nil {
+				if pageToken != drive.PageToken {
+					t.Errorf("pageToken should not be updated after an error, got: %s", pageToken)
+				}
+
+				return
+			}
+
+			if pageToken != tc.changes.drive.PageToken {
+				t.Errorf("pageToken was not updated, got: %s", pageToken)
+			}
+
+			folders, files := getItems(t, store, drive.ID)
+
+			if !reflect.DeepEqual(folders, tc.expected.folders) {
+				t.Log(folders)
+				t.Log(tc.expected.folders)
+				t.Error("Folders do not match")
+			}
+
+			if !reflect.DeepEqual(files, tc.expected.files) {
+				t.Log(files)
+				t.Log(tc.expected.files)
+				t.Error("Files do not match")
+			}
+
+			if children := getIndex(t, store, drive.ID, bucketChildren); !reflect.DeepEqual(children, tc.expected.children) {
+				t.Errorf("Children index does not match: %q", children)
+			}
+
+			if links := getIndex(t, store, drive.ID, bucketLinks); !reflect.DeepEqual(links, tc.expected.links) {
+				t.Errorf("Links index does not match: %q", links)
+			}
+		})
+	}
+}
+
+func TestCancelledSync(t *testing.T) {
+	store := setupTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := store.FullSyncContext(ctx, ds.Drive{ID: "drive", PageToken: "1"}, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+
+	if _, err := store.PageToken("drive"); !errors.Is(err, ds.ErrFullSync) {
+		t.Errorf("Expected ErrFullSync, got: %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package bolt
+
+import (
+	"testing"
+
+	ds "github.com/m-rots/bernard/datastore"
+	"github.com/m-rots/bernard/datastore/datastoretest"
+)
+
+func TestConformance(t *testing.T) {
+	datastoretest.Run(t, func(t *testing.T) ds.Datastore {
+		return setupTest(t)
+	}, func(t *testing.T, store ds.Datastore, driveID string) ([]ds.Folder, []ds.File) {
+		return getItems(t, store.(*Datastore), driveID)
+	})
+}
+
+func TestShortcutConformance(t *testing.T) {
+	datastoretest.RunShortcuts(t, func(t *testing.T) ds.ShortcutResolver {
+		return setupTest(t)
+	})
+}
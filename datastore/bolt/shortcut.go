@@ -0,0 +1,73 @@
+package bolt
+
+import (
+	"context"
+	"fmt"
+
+	ds "github.com/m-rots/bernard/datastore"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// ResolveShortcut returns the target of the shortcut with the provided ID.
+//
+// As a shortcut can point to a file or folder in another Shared Drive,
+// the target is looked up in all Shared Drives present in the datastore.
+// The Shared Drive of the shortcut takes precedence.
+func (store *Datastore) ResolveShortcut(ctx context.Context, driveID string, shortcutID string) (file *ds.File, folder *ds.Folder, err error) {
+	err = store.DB.View(func(tx *bbolt.Tx) error {
+		d := openDrive(tx, driveID)
+		if d == nil {
+			return fmt.Errorf("%v: %w", shortcutID, ds.ErrNotFound)
+		}
+
+		shortcut, err := d.file(shortcutID)
+		if err != nil {
+			return err
+		}
+
+		if shortcut == nil || shortcut.Shortcut == nil {
+			return fmt.Errorf("%v is not a shortcut: %w", shortcutID, ds.ErrNotFound)
+		}
+
+		targetID := shortcut.Shortcut.TargetID
+
+		// Look in the Shared Drive of the shortcut first.
+		drives := []*driveTx{d}
+		err = tx.Bucket(bucketDrives).ForEach(func(id, _ []byte) error {
+			if string(id) != driveID {
+				drives = append(drives, openDrive(tx, string(id)))
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, d := range drives {
+			if file, err = d.file(targetID); err != nil || file != nil {
+				return err
+			}
+
+			// The root folder of a Shared Drive is not a valid target.
+			if folder, err = d.folder(targetID); err != nil || (folder != nil && folder.Parent != "") {
+				return err
+			}
+
+			folder = nil
+		}
+
+		return fmt.Errorf("%v: %w", targetID, ds.ErrNotFound)
+	})
+
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file, folder, nil
+}
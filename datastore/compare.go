@@ -0,0 +1,66 @@
+package datastore
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// FolderChanged reports whether any of the stored fields differ between the old and new state of a folder.
+//
+// The Path is not compared, as it is derived from the names and parents of the ancestors.
+func FolderChanged(old, new Folder) bool {
+	return old.Name != new.Name || old.Parent != new.Parent || old.Trashed != new.Trashed ||
+		parentsChanged(old.AdditionalParents, new.AdditionalParents) || extraChanged(old.Extra, new.Extra)
+}
+
+// FileChanged reports whether any of the stored fields differ between the old and new state of a file.
+//
+// The Path is not compared, as it is derived from the names and parents of the ancestors.
+func FileChanged(old, new File) bool {
+	return old.Name != new.Name || old.Parent != new.Parent || old.Trashed != new.Trashed ||
+		old.Size != new.Size || old.MD5 != new.MD5 || old.SHA1 != new.SHA1 || old.SHA256 != new.SHA256 ||
+		old.MimeType != new.MimeType || !old.CreatedTime.Equal(new.CreatedTime) ||
+		!old.ModifiedTime.Equal(new.ModifiedTime) || old.Version != new.Version ||
+		old.FileExtension != new.FileExtension || old.OriginalFilename != new.OriginalFilename ||
+		old.HeadRevisionID != new.HeadRevisionID || parentsChanged(old.AdditionalParents, new.AdditionalParents) ||
+		shortcutChanged(old.Shortcut, new.Shortcut) || extraChanged(old.Extra, new.Extra)
+}
+
+// parentsChanged reports whether the additional parents differ between the old and new state.
+func parentsChanged(old, new []string) bool {
+	if len(old) != len(new) {
+		return true
+	}
+
+	for i := range new {
+		if old[i] != new[i] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// extraChanged reports whether any of the additional properties differ between the old and new state.
+func extraChanged(old, new map[string]json.RawMessage) bool {
+	if len(old) != len(new) {
+		return true
+	}
+
+	for key, value := range new {
+		if !bytes.Equal(old[key], value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shortcutChanged reports whether the target of a shortcut differs between the old and new state.
+func shortcutChanged(old, new *Shortcut) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+
+	return *old != *new
+}
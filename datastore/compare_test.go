@@ -0,0 +1,61 @@
+package datastore
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFolderChanged(t *testing.T) {
+	old := Folder{ID: "A", Name: "Folder A", Parent: "drive", AdditionalParents: []string{"B"},
+		Extra: map[string]json.RawMessage{"properties": json.RawMessage(`{"a":1}`)}}
+
+	tests := []struct {
+		name    string
+		new     func(f Folder) Folder
+		changed bool
+	}{
+		{"unchanged", func(f Folder) Folder { return f }, false},
+		{"path", func(f Folder) Folder { f.Path = "/Folder A"; return f }, false},
+		{"name", func(f Folder) Folder { f.Name = "Folder B"; return f }, true},
+		{"parent", func(f Folder) Folder { f.Parent = "C"; return f }, true},
+		{"trashed", func(f Folder) Folder { f.Trashed = true; return f }, true},
+		{"additional parents", func(f Folder) Folder { f.AdditionalParents = []string{"C"}; return f }, true},
+		{"extra", func(f Folder) Folder {
+			f.Extra = map[string]json.RawMessage{"properties": json.RawMessage(`{"a":2}`)}
+			return f
+		}, true},
+	}
+
+	for _, tc := range tests {
+		if changed := FolderChanged(old, tc.new(old)); changed != tc.changed {
+			t.Errorf("%s: expected %v, got: %v", tc.name, tc.changed, changed)
+		}
+	}
+}
+
+func TestFileChanged(t *testing.T) {
+	created := time.Date(2020, 3, 2, 12, 30, 15, 0, time.UTC)
+	old := File{ID: "Z", Name: "File Z", Parent: "A", MD5: "ZZZ", Size: 10, CreatedTime: created, ModifiedTime: created,
+		Shortcut: &Shortcut{TargetID: "Y", TargetMimeType: "image/png"}}
+
+	tests := []struct {
+		name    string
+		new     func(f File) File
+		changed bool
+	}{
+		{"unchanged", func(f File) File { return f }, false},
+		{"time zone", func(f File) File { f.CreatedTime = created.In(time.FixedZone("CET", 3600)); return f }, false},
+		{"equal shortcut", func(f File) File { f.Shortcut = &Shortcut{TargetID: "Y", TargetMimeType: "image/png"}; return f }, false},
+		{"md5", func(f File) File { f.MD5 = "YYY"; return f }, true},
+		{"modified time", func(f File) File { f.ModifiedTime = created.Add(time.Second); return f }, true},
+		{"shortcut target", func(f File) File { f.Shortcut = &Shortcut{TargetID: "X"}; return f }, true},
+		{"no shortcut", func(f File) File { f.Shortcut = nil; return f }, true},
+	}
+
+	for _, tc := range tests {
+		if changed := FileChanged(old, tc.new(old)); changed != tc.changed {
+			t.Errorf("%s: expected %v, got: %v", tc.name, tc.changed, changed)
+		}
+	}
+}
@@ -5,12 +5,15 @@
 // though a SQLite reference datastore does exist, which could work with other SQL
 // drivers as well.
 //
-// Finally, this package also serves three common errors which may occur
+// Finally, this package also serves five common errors which may occur
 // at the datastore layer.
 package datastore
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"time"
 )
 
 // Folder is a minimal representation of a file with mimeType `application/vnd.google-apps.folder`
@@ -20,6 +23,21 @@ type Folder struct {
 	Name    string
 	Parent  string
 	Trashed bool
+
+	// Path is the full path of the folder within the Shared Drive, such as `/Movies/2020`,
+	// following the primary Parent of every folder. The root folder has the path `/`.
+	//
+	// Path is only set by datastores which maintain the paths of the items they store,
+	// Bernard itself does not set the Path of the folders it fetches.
+	Path string
+
+	// AdditionalParents are the IDs of all parents next to the primary Parent.
+	// AdditionalParents is nil when the folder only has a single parent.
+	AdditionalParents []string
+
+	// Extra contains the raw JSON of the additional properties requested with bernard.WithFields,
+	// keyed on the name of the property. Extra is nil when no additional properties are present.
+	Extra map[string]json.RawMessage
 }
 
 // File is a minimal representation of all other files within Google Drive which do not have
@@ -31,6 +49,49 @@ type File struct {
 	Trashed bool
 	Size    uint64
 	MD5     string
+	SHA1    string
+	SHA256  string
+
+	// Path is the full path of the file within the Shared Drive, such as `/Movies/2020/foo.mkv`,
+	// following the primary Parent of the file and its folders.
+	//
+	// Path is only set by datastores which maintain the paths of the items they store,
+	// Bernard itself does not set the Path of the files it fetches.
+	Path string
+
+	// AdditionalParents are the IDs of all parents next to the primary Parent.
+	// AdditionalParents is nil when the file only has a single parent.
+	AdditionalParents []string
+
+	MimeType     string
+	CreatedTime  time.Time
+	ModifiedTime time.Time
+
+	// Version is a monotonically increasing number, which changes
+	// whenever the file or its metadata changes.
+	Version int64
+
+	// FileExtension and OriginalFilename are only set for files with binary content.
+	FileExtension    string
+	OriginalFilename string
+
+	// HeadRevisionID is the ID of the current revision of a file with binary content.
+	HeadRevisionID string
+
+	// Shortcut is only set when the file is a shortcut to another file or folder.
+	Shortcut *Shortcut
+
+	// Extra contains the raw JSON of the additional properties requested with bernard.WithFields,
+	// keyed on the name of the property. Extra is nil when no additional properties are present.
+	Extra map[string]json.RawMessage
+}
+
+// Shortcut holds the target of a file with mimeType `application/vnd.google-apps.shortcut`.
+//
+// The target of a shortcut is not necessarily part of the same Shared Drive.
+type Shortcut struct {
+	TargetID       string
+	TargetMimeType string
 }
 
 // Drive is a minimal representation of the Shared Drive itself.
@@ -63,10 +124,14 @@ type Datastore interface {
 	//
 	// 2. Changed files are upserted in the database.
 	//
-	// 3. Removed IDs are recursively deleted from the database
+	// 3. Removed IDs are deleted from the database. Unknown IDs are ignored.
 	//
 	// 4. The pageToken is saved and the transaction is commited.
 	//
+	// Removals are not recursive. Removing a folder which still has children after all changes
+	// have been applied results in ErrDataAnomaly, while a removed folder is removed from
+	// the additional parents of other items.
+	//
 	// If an error occurs during the inserting, such as a foreign key constraint,
 	// the entire transaction should be rolled back.
 	PartialSync(drive Drive, changedFolders []Folder, changedFiles []File, removedIDs []string) error
@@ -75,6 +140,134 @@ type Datastore interface {
 	PageToken(driveID string) (string, error)
 }
 
+// A ContextDatastore is a Datastore which supports cancellation through a context.Context.
+//
+// Bernard checks whether the Datastore implements the ContextDatastore interface
+// and prefers these methods over their counterparts without context.
+// When the context is cancelled, any open transaction should be rolled back
+// and the error of the context should be returned.
+type ContextDatastore interface {
+	Datastore
+
+	// FullSyncContext is FullSync with a context.
+	FullSyncContext(ctx context.Context, drive Drive, folders []Folder, files []File) error
+
+	// PartialSyncContext is PartialSync with a context.
+	PartialSyncContext(ctx context.Context, drive Drive, changedFolders []Folder, changedFiles []File, removedIDs []string) error
+
+	// PageTokenContext is PageToken with a context.
+	PageTokenContext(ctx context.Context, driveID string) (string, error)
+}
+
+// A StreamingDatastore receives the content of a full sync page by page
+// instead of all at once. This keeps the memory usage of a full sync bounded,
+// regardless of the number of files within the Shared Drive.
+//
+// Bernard prefers the StreamingDatastore over the FullSync method of the Datastore
+// when the Datastore implements this interface.
+type StreamingDatastore interface {
+	Datastore
+
+	// BeginFullSync starts a full sync of the provided Drive.
+	//
+	// Like FullSync, the pageToken should be saved and the driveID should be
+	// inserted as a root folder. None of these changes may be visible until
+	// the FullSyncWriter is committed.
+	BeginFullSync(ctx context.Context, drive Drive) (FullSyncWriter, error)
+}
+
+// A FullSyncWriter writes the pages of a single full sync to the datastore.
+//
+// The folders within a page are ordered on hierarchy. However, folders and files
+// may reference parents which are only provided in a later page. Therefore,
+// the relationship constraints can only be checked once all pages are written.
+type FullSyncWriter interface {
+	// WritePage inserts the folders and files of a single page.
+	//
+	// The nextPageToken points to the page following this one
+	// and is empty when this is the last page.
+	WritePage(ctx context.Context, folders []Folder, files []File, nextPageToken string) error
+
+	// Commit checks the relationship constraints and makes all written pages visible.
+	// A data anomaly in these constraints should roll back the entire full sync.
+	Commit(ctx context.Context) error
+
+	// Rollback discards all written pages which cannot be resumed.
+	// Calling Rollback after Commit is a no-op.
+	Rollback() error
+}
+
+// A Checkpoint describes the progress of an interrupted full sync.
+type Checkpoint struct {
+	// Drive is the Drive as provided to BeginFullSync,
+	// including the pageToken from before the full sync started.
+	Drive Drive
+
+	// NextPageToken is the pageToken of the first page which has not been written yet.
+	NextPageToken string
+
+	// Pages is the number of pages written so far.
+	// When Pages is larger than 0 and the NextPageToken is empty,
+	// all pages have been written and only the commit remains.
+	Pages int
+}
+
+// A ResumableDatastore persists every page of a full sync as soon as it is written,
+// together with a Checkpoint. An interrupted full sync can then be resumed from the last
+// written page, even after a restart of the programme.
+//
+// The pages written by a resumable FullSyncWriter survive a Rollback.
+// They are only discarded once the full sync is committed, the commit finds a data anomaly
+// or another full sync of the same Drive begins.
+type ResumableDatastore interface {
+	StreamingDatastore
+
+	// Checkpoint returns the Checkpoint of the interrupted full sync of the driveID.
+	// ErrNoCheckpoint is returned when no full sync of the driveID is in progress.
+	Checkpoint(ctx context.Context, driveID string) (Checkpoint, error)
+
+	// ResumeFullSync continues the full sync of the provided Checkpoint.
+	ResumeFullSync(ctx context.Context, checkpoint Checkpoint) (FullSyncWriter, error)
+}
+
+// A ShortcutResolver is a Datastore which can resolve a shortcut to its target.
+type ShortcutResolver interface {
+	Datastore
+
+	// ResolveShortcut returns the target of the shortcut with the provided ID
+	// within the Shared Drive of the driveID. Either the file or the folder is returned.
+	//
+	// ErrNotFound is returned when either the shortcut or its target
+	// is not present in the datastore.
+	ResolveShortcut(ctx context.Context, driveID string, shortcutID string) (*File, *Folder, error)
+}
+
+// A Reader is a Datastore which provides read access to the folders and files it stores.
+// The returned folders and files carry their Path.
+//
+// ErrNotFound is returned when the requested item is not present in the datastore.
+type Reader interface {
+	Datastore
+
+	// GetFile returns the file with the provided ID within the Shared Drive of the driveID.
+	GetFile(ctx context.Context, driveID string, id string) (*File, error)
+
+	// GetFolder returns the folder with the provided ID within the Shared Drive of the driveID.
+	// The ID of the Shared Drive itself returns the root folder.
+	GetFolder(ctx context.Context, driveID string, id string) (*Folder, error)
+
+	// ListChildren returns the folders and files within the folder, sorted on name.
+	// Items of which the folder is one of the AdditionalParents are included as well.
+	ListChildren(ctx context.Context, driveID string, folderID string) ([]Folder, []File, error)
+
+	// Lookup returns the file or folder at the slash-separated path, relative to the root
+	// of the Shared Drive. Either the file or the folder is returned.
+	//
+	// As multiple items within a folder can share the same name, folders take precedence
+	// over files, after which the item with the lowest ID is picked.
+	Lookup(ctx context.Context, driveID string, path string) (*File, *Folder, error)
+}
+
 // ErrDataAnomaly indicates an error in the relationship constraints within the datastore.
 // This error might occur when the Google Drive API has not processed all changes yet,
 // and therefore returns an incomplete list of changes.
@@ -95,3 +288,9 @@ var ErrDatabase = errors.New("datastore: database related error")
 // ErrFullSync indicates the database is missing the pageToken variable,
 // which is exclusively the result of not running a full sync beforehand.
 var ErrFullSync = errors.New("datastore: requires full sync")
+
+// ErrNoCheckpoint indicates no interrupted full sync exists which can be resumed.
+var ErrNoCheckpoint = errors.New("datastore: no checkpoint")
+
+// ErrNotFound indicates the requested item is not present in the datastore.
+var ErrNotFound = errors.New("datastore: not found")
@@ -0,0 +1,355 @@
+// Package datastoretest provides a conformance test suite for implementations
+// of the Bernard Datastore interface.
+//
+// An implementation runs the suite from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		datastoretest.Run(t, func(t *testing.T) ds.Datastore {
+//			return newEmptyDatastore(t)
+//		}, listItems)
+//	}
+//
+// As the Datastore interface does not provide a way to read the folders and files,
+// the implementation supplies an ItemsFunc to list the content of a Shared Drive.
+package datastoretest
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// A SetupFunc returns an empty Datastore. It is called once for every test of the suite.
+type SetupFunc func(t *testing.T) ds.Datastore
+
+// An ItemsFunc returns all folders, including the root folder,
+// and all files of the Shared Drive, sorted on ID.
+//
+// Slices and maps without any elements should be nil.
+type ItemsFunc func(t *testing.T, store ds.Datastore, driveID string) ([]ds.Folder, []ds.File)
+
+// Run runs the conformance test suite against the Datastores returned by setup.
+func Run(t *testing.T, setup SetupFunc, items ItemsFunc) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store ds.Datastore, items ItemsFunc)
+	}{
+		{"PageToken requires full sync", testRequiresFullSync},
+		{"FullSync", testFullSync},
+		{"FullSync data anomaly", testFullSyncAnomaly},
+		{"PartialSync upserts", testPartialSyncUpserts},
+		{"PartialSync removals", testPartialSyncRemovals},
+		{"PartialSync data anomaly", testPartialSyncAnomaly},
+		{"PartialSync removal data anomaly", testPartialSyncRemovalAnomaly},
+		{"Drive rename", testDriveRename},
+		{"Multiple drives", testMultipleDrives},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, setup(t), items)
+		})
+	}
+}
+
+var drive = ds.Drive{ID: "drive", Name: "Shared Drive", PageToken: "1"}
+
+var timestamp = time.Date(2020, 3, 2, 12, 30, 15, 123000000, time.UTC)
+
+// initial is the state of the Drive after the full sync of most tests.
+var initial = struct {
+	folders []ds.Folder
+	files   []ds.File
+}{
+	folders: []ds.Folder{
+		{ID: "A", Name: "Folder A", Parent: "drive"},
+		{ID: "B", Name: "Folder B", Parent: "A", Trashed: true},
+		{ID: "C", Name: "Folder C", Parent: "drive", Extra: map[string]json.RawMessage{"properties": json.RawMessage(`{"c":1}`)}},
+	},
+	files: []ds.File{
+		{ID: "Z", Name: "File Z", Parent: "drive", Size: 10, MD5: "ZZZ", SHA1: "ZZZ1", SHA256: "ZZZ256",
+			MimeType: "image/png", CreatedTime: timestamp, ModifiedTime: timestamp.Add(time.Hour), Version: 3,
+			FileExtension: "png", OriginalFilename: "z.png", HeadRevisionID: "rev-z"},
+		{ID: "Y", Name: "File Y", Parent: "B", Size: 20, MD5: "YYY", Trashed: true, AdditionalParents: []string{"C"}},
+		{ID: "X", Name: "To Folder A", Parent: "C", MimeType: "application/vnd.google-apps.shortcut",
+			Shortcut: &ds.Shortcut{TargetID: "A", TargetMimeType: "application/vnd.google-apps.folder"}},
+	},
+}
+
+// rootFolder returns the folder a Datastore should store for the Drive itself.
+func rootFolder(d ds.Drive) ds.Folder {
+	return ds.Folder{ID: d.ID, Name: d.Name}
+}
+
+// fullSync fully synchronises the initial state and fails the test on an error.
+func fullSync(t *testing.T, store ds.Datastore) {
+	t.Helper()
+
+	if err := store.FullSync(drive, initial.folders, initial.files); err != nil {
+		t.Fatalf("Unexpected error at full sync: %v", err)
+	}
+}
+
+// expectPageToken fails the test when the pageToken of the Drive does not match.
+func expectPageToken(t *testing.T, store ds.Datastore, driveID string, expected string) {
+	t.Helper()
+
+	pageToken, err := store.PageToken(driveID)
+	if err != nil {
+		t.Fatalf("Could not get pageToken: %v", err)
+	}
+
+	if pageToken != expected {
+		t.Errorf("pageToken %q does not match the expected %q", pageToken, expected)
+	}
+}
+
+// expectItems fails the test when the content of the Drive does not match.
+func expectItems(t *testing.T, store ds.Datastore, items ItemsFunc, driveID string, folders []ds.Folder, files []ds.File) {
+	t.Helper()
+
+	actualFolders, actualFiles := items(t, store, driveID)
+
+	// Not every datastore maintains paths, so they are left out of the comparison.
+	for i := range actualFolders {
+		actualFolders[i].Path = ""
+	}
+
+	for i := range actualFiles {
+		actualFiles[i].Path = ""
+	}
+
+	if !reflect.DeepEqual(actualFolders, folders) {
+		t.Log(actualFolders)
+		t.Log(folders)
+		t.Error("Folders do not match")
+	}
+
+	if !reflect.DeepEqual(actualFiles, files) {
+		t.Log(actualFiles)
+		t.Log(files)
+		t.Error("Files do not match")
+	}
+}
+
+func testRequiresFullSync(t *testing.T, store ds.Datastore, items ItemsFunc) {
+	_, err := store.PageToken(drive.ID)
+	if !errors.Is(err, ds.ErrFullSync) {
+		t.Errorf("Expected ErrFullSync, got: %v", err)
+	}
+}
+
+func testFullSync(t *testing.T, store ds.Datastore, items ItemsFunc) {
+	// Children are listed before their parents to check whether
+	// the relationship constraints are only checked at the end.
+	folders := []ds.Folder{initial.folders[1], initial.folders[0], initial.folders[2]}
+	files := initial.files
+
+	if err := store.FullSync(drive, folders, files); err != nil {
+		t.Fatalf("Unexpected error at full sync: %v", err)
+	}
+
+	expectPageToken(t, store, drive.ID, drive.PageToken)
+	expectItems(t, store, items, drive.ID,
+		[]ds.Folder{initial.folders[0], initial.folders[1], initial.folders[2], rootFolder(drive)},
+		[]ds.File{initial.files[2], initial.files[1], initial.files[0]},
+	)
+}
+
+func testFullSyncAnomaly(t *testing.T, store ds.Datastore, items ItemsFunc) {
+	tests := []struct {
+		name    string
+		folders []ds.Folder
+		files   []ds.File
+	}{
+		{"orphaned file", nil, []ds.File{{ID: "Z", Name: "File Z", Parent: "unknown"}}},
+		{"orphaned folder", []ds.Folder{{ID: "A", Name: "Folder A", Parent: "unknown"}}, nil},
+		{"unknown additional parent", nil, []ds.File{{ID: "Z", Name: "File Z", Parent: "drive", AdditionalParents: []string{"unknown"}}}},
+	}
+
+	for _, tc := range tests {
+		err := store.FullSync(drive, tc.folders, tc.files)
+		if !errors.Is(err, ds.ErrDataAnomaly) {
+			t.Errorf("%s: expected a data anomaly, got: %v", tc.name, err)
+		}
+
+		// Nothing should be written on a data anomaly.
+		if _, err = store.PageToken(drive.ID); !errors.Is(err, ds.ErrFullSync) {
+			t.Errorf("%s: expected ErrFullSync, got: %v", tc.name, err)
+		}
+	}
+}
+
+func testPartialSyncUpserts(t *testing.T, store ds.Datastore, items ItemsFunc) {
+	fullSync(t, store)
+
+	changes := ds.Drive{ID: drive.ID, PageToken: "2"}
+
+	folders := []ds.Folder{
+		{ID: "B", Name: "Folder B", Parent: "drive"},                               // moved and restored
+		{ID: "D", Name: "Folder D", Parent: "B"},                                   // added
+		{ID: "C", Name: "Renamed C", Parent: "drive"},                              // renamed, without extra
+		{ID: "E", Name: "Folder E", Parent: "D", Trashed: true},                    // added to a new folder
+		{ID: "A", Name: "Folder A", Parent: "drive", Trashed: false},               // unchanged
+		{ID: "F", Name: "Folder F", Parent: "A", AdditionalParents: []string{"D"}}, // added with an additional parent
+	}
+
+	files := []ds.File{
+		{ID: "Z", Name: "File Z", Parent: "D", Size: 30, MD5: "ZZZ2", Version: 4,
+			CreatedTime: timestamp, ModifiedTime: timestamp.Add(2 * time.Hour)}, // moved and modified
+		{ID: "Y", Name: "File Y", Parent: "B", Size: 20, MD5: "YYY"}, // restored, without additional parents
+		{ID: "W", Name: "File W", Parent: "E", AdditionalParents: []string{"A", "D"},
+			Extra: map[string]json.RawMessage{"starred": json.RawMessage(`true`)}}, // added
+	}
+
+	if err := store.PartialSync(changes, folders, files, nil); err != nil {
+		t.Fatalf("Unexpected error at partial sync: %v", err)
+	}
+
+	expectPageToken(t, store, drive.ID, changes.PageToken)
+	expectItems(t, store, items, drive.ID,
+		[]ds.Folder{folders[4], folders[0], folders[2], folders[1], folders[3], folders[5], rootFolder(drive)},
+		[]ds.File{files[2], initial.files[2], files[1], files[0]},
+	)
+}
+
+func testPartialSyncRemovals(t *testing.T, store ds.Datastore, items ItemsFunc) {
+	fullSync(t, store)
+
+	changes := ds.Drive{ID: drive.ID, PageToken: "2"}
+
+	// Folder B is removed together with its only child Y,
+	// while folder C is removed after its child X moved elsewhere.
+	// Unknown IDs are ignored.
+	files := []ds.File{{ID: "X", Name: "To Folder A", Parent: "A"}}
+	removed := []string{"Y", "B", "C", "Z", "unknown"}
+
+	if err := store.PartialSync(changes, nil, files, removed); err != nil {
+		t.Fatalf("Unexpected error at partial sync: %v", err)
+	}
+
+	expectPageToken(t, store, drive.ID, changes.PageToken)
+	expectItems(t, store, items, drive.ID,
+		[]ds.Folder{initial.folders[0], rootFolder(drive)},
+		files,
+	)
+}
+
+func testPartialSyncAnomaly(t *testing.T, store ds.Datastore, items ItemsFunc) {
+	fullSync(t, store)
+
+	tests := []struct {
+		name    string
+		folders []ds.Folder
+		files   []ds.File
+	}{
+		{"orphaned file", nil, []ds.File{{ID: "Z", Name: "File Z", Parent: "unknown"}}},
+		{"orphaned folder", []ds.Folder{{ID: "D", Name: "Folder D", Parent: "unknown"}}, nil},
+		{"unknown additional parent", nil, []ds.File{{ID: "Z", Name: "File Z", Parent: "drive", AdditionalParents: []string{"unknown"}}}},
+	}
+
+	for _, tc := range tests {
+		err := store.PartialSync(ds.Drive{ID: drive.ID, PageToken: "2"}, tc.folders, tc.files, nil)
+		if !errors.Is(err, ds.ErrDataAnomaly) {
+			t.Errorf("%s: expected a data anomaly, got: %v", tc.name, err)
+		}
+	}
+
+	// Nothing should be written on a data anomaly.
+	expectPageToken(t, store, drive.ID, drive.PageToken)
+	expectItems(t, store, items, drive.ID,
+		[]ds.Folder{initial.folders[0], initial.folders[1], initial.folders[2], rootFolder(drive)},
+		[]ds.File{initial.files[2], initial.files[1], initial.files[0]},
+	)
+}
+
+func testPartialSyncRemovalAnomaly(t *testing.T, store ds.Datastore, items ItemsFunc) {
+	fullSync(t, store)
+
+	tests := []struct {
+		name    string
+		removed []string
+	}{
+		{"folder with a file", []string{"B"}},
+		{"folder with a folder", []string{"A"}},
+		{"folder with a shortcut", []string{"C", "Y"}},
+	}
+
+	for _, tc := range tests {
+		err := store.PartialSync(ds.Drive{ID: drive.ID, PageToken: "2"}, nil, nil, tc.removed)
+		if !errors.Is(err, ds.ErrDataAnomaly) {
+			t.Errorf("%s: expected a data anomaly, got: %v", tc.name, err)
+		}
+	}
+
+	// Nothing should be written on a data anomaly.
+	expectPageToken(t, store, drive.ID, drive.PageToken)
+	expectItems(t, store, items, drive.ID,
+		[]ds.Folder{initial.folders[0], initial.folders[1], initial.folders[2], rootFolder(drive)},
+		[]ds.File{initial.files[2], initial.files[1], initial.files[0]},
+	)
+}
+
+func testDriveRename(t *testing.T, store ds.Datastore, items ItemsFunc) {
+	fullSync(t, store)
+
+	// An empty name indicates the name of the Drive has not changed.
+	if err := store.PartialSync(ds.Drive{ID: drive.ID, PageToken: "2"}, nil, nil, nil); err != nil {
+		t.Fatalf("Unexpected error at partial sync: %v", err)
+	}
+
+	folders, _ := items(t, store, drive.ID)
+	if root := folders[len(folders)-1]; !reflect.DeepEqual(root, rootFolder(drive)) {
+		t.Errorf("Root folder should not be renamed: %v", root)
+	}
+
+	renamed := ds.Drive{ID: drive.ID, Name: "Renamed Drive", PageToken: "3"}
+	if err := store.PartialSync(renamed, nil, nil, nil); err != nil {
+		t.Fatalf("Unexpected error at partial sync: %v", err)
+	}
+
+	expectPageToken(t, store, drive.ID, renamed.PageToken)
+
+	folders, _ = items(t, store, drive.ID)
+	if root := folders[len(folders)-1]; !reflect.DeepEqual(root, rootFolder(renamed)) {
+		t.Errorf("Root folder is not renamed: %v", root)
+	}
+}
+
+func testMultipleDrives(t *testing.T, store ds.Datastore, items ItemsFunc) {
+	fullSync(t, store)
+
+	// The other Drive uses the same IDs as the first Drive.
+	other := ds.Drive{ID: "other", Name: "Other Drive", PageToken: "10"}
+	otherFolders := []ds.Folder{{ID: "A", Name: "Other A", Parent: "other"}}
+	otherFiles := []ds.File{{ID: "Z", Name: "Other Z", Parent: "A"}}
+
+	if err := store.FullSync(other, otherFolders, otherFiles); err != nil {
+		t.Fatalf("Unexpected error at full sync of other: %v", err)
+	}
+
+	// Removals should only affect a single Drive.
+	if err := store.PartialSync(ds.Drive{ID: other.ID, PageToken: "11"}, nil, nil, []string{"Z", "A"}); err != nil {
+		t.Fatalf("Unexpected error at partial sync of other: %v", err)
+	}
+
+	// Folders of another Drive can not be used as a parent.
+	err := store.PartialSync(ds.Drive{ID: other.ID, PageToken: "12"}, nil, []ds.File{{ID: "Y", Name: "File Y", Parent: "C"}}, nil)
+	if !errors.Is(err, ds.ErrDataAnomaly) {
+		t.Errorf("Expected a data anomaly, got: %v", err)
+	}
+
+	expectPageToken(t, store, drive.ID, drive.PageToken)
+	expectPageToken(t, store, other.ID, "11")
+
+	expectItems(t, store, items, drive.ID,
+		[]ds.Folder{initial.folders[0], initial.folders[1], initial.folders[2], rootFolder(drive)},
+		[]ds.File{initial.files[2], initial.files[1], initial.files[0]},
+	)
+
+	expectItems(t, store, items, other.ID, []ds.Folder{rootFolder(other)}, nil)
+}
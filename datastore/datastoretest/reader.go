@@ -0,0 +1,208 @@
+package datastoretest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// A ReaderSetupFunc returns an empty Reader. It is called once for every test of the suite.
+type ReaderSetupFunc func(t *testing.T) ds.Reader
+
+// RunReader runs the conformance test suite of the Reader interface
+// against the Readers returned by setup.
+func RunReader(t *testing.T, setup ReaderSetupFunc) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store ds.Reader)
+	}{
+		{"GetFile", testGetFile},
+		{"GetFolder", testGetFolder},
+		{"ListChildren", testListChildren},
+		{"Lookup", testLookup},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := setup(t)
+			fullSync(t, store)
+
+			// Folder D shares its name with file Z, and folder E with folder A.
+			err := store.PartialSync(ds.Drive{ID: drive.ID, PageToken: "2"},
+				[]ds.Folder{
+					{ID: "D", Name: "File Z", Parent: "drive"},
+					{ID: "E", Name: "Folder A", Parent: "drive"},
+				}, nil, nil)
+
+			if err != nil {
+				t.Fatalf("Unexpected error at partial sync: %v", err)
+			}
+
+			tc.test(t, store)
+		})
+	}
+}
+
+// The items of the Drive as returned by a Reader, including their paths.
+var (
+	root    = ds.Folder{ID: drive.ID, Name: drive.Name, Path: "/"}
+	folderA = folderAt(initial.folders[0], "/Folder A")
+	folderB = folderAt(initial.folders[1], "/Folder A/Folder B")
+	folderC = folderAt(initial.folders[2], "/Folder C")
+	folderD = ds.Folder{ID: "D", Name: "File Z", Parent: "drive", Path: "/File Z"}
+	folderE = ds.Folder{ID: "E", Name: "Folder A", Parent: "drive", Path: "/Folder A"}
+	fileZ   = fileAt(initial.files[0], "/File Z")
+	fileY   = fileAt(initial.files[1], "/Folder A/Folder B/File Y")
+	fileX   = fileAt(initial.files[2], "/Folder C/To Folder A")
+)
+
+// folderAt returns the folder with the provided path.
+func folderAt(f ds.Folder, path string) ds.Folder {
+	f.Path = path
+	return f
+}
+
+// fileAt returns the file with the provided path.
+func fileAt(f ds.File, path string) ds.File {
+	f.Path = path
+	return f
+}
+
+func testGetFile(t *testing.T, store ds.Reader) {
+	ctx := context.Background()
+
+	file, err := store.GetFile(ctx, drive.ID, "Y")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(*file, fileY) {
+		t.Errorf("File does not match: %v", *file)
+	}
+
+	for _, id := range []string{"A", "unknown"} {
+		if _, err = store.GetFile(ctx, drive.ID, id); !errors.Is(err, ds.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got: %v", id, err)
+		}
+	}
+
+	if _, err = store.GetFile(ctx, "unknown", "Y"); !errors.Is(err, ds.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown Drive, got: %v", err)
+	}
+}
+
+func testGetFolder(t *testing.T, store ds.Reader) {
+	ctx := context.Background()
+
+	folder, err := store.GetFolder(ctx, drive.ID, "C")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(*folder, folderC) {
+		t.Errorf("Folder does not match: %v", *folder)
+	}
+
+	folder, err = store.GetFolder(ctx, drive.ID, drive.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error for the root folder: %v", err)
+	}
+
+	if !reflect.DeepEqual(*folder, root) {
+		t.Errorf("Root folder does not match: %v", *folder)
+	}
+
+	for _, id := range []string{"Z", "unknown"} {
+		if _, err = store.GetFolder(ctx, drive.ID, id); !errors.Is(err, ds.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got: %v", id, err)
+		}
+	}
+}
+
+func testListChildren(t *testing.T, store ds.Reader) {
+	ctx := context.Background()
+
+	tests := []struct {
+		folderID string
+		folders  []ds.Folder
+		files    []ds.File
+	}{
+		{
+			folderID: drive.ID,
+			folders:  []ds.Folder{folderD, folderA, folderE, folderC},
+			files:    []ds.File{fileZ},
+		},
+		{
+			// File Y is linked to folder C as an additional parent.
+			folderID: "C",
+			files:    []ds.File{fileY, fileX},
+		},
+		{
+			folderID: "D",
+		},
+	}
+
+	for _, tc := range tests {
+		folders, files, err := store.ListChildren(ctx, drive.ID, tc.folderID)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.folderID, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(folders, tc.folders) {
+			t.Log(folders)
+			t.Errorf("%s: folders do not match", tc.folderID)
+		}
+
+		if !reflect.DeepEqual(files, tc.files) {
+			t.Log(files)
+			t.Errorf("%s: files do not match", tc.folderID)
+		}
+	}
+
+	if _, _, err := store.ListChildren(ctx, drive.ID, "unknown"); !errors.Is(err, ds.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got: %v", err)
+	}
+}
+
+func testLookup(t *testing.T, store ds.Reader) {
+	ctx := context.Background()
+
+	tests := []struct {
+		path   string
+		file   *ds.File
+		folder *ds.Folder
+		err    error
+	}{
+		{path: "/", folder: &root},
+		{path: "", folder: &root},
+		{path: "/Folder A", folder: &folderA},
+		{path: "Folder A/Folder B/", folder: &folderB},
+		{path: "/Folder A/Folder B/File Y", file: &fileY},
+		{path: "/Folder C/File Y", file: &fileY},
+		{path: "/File Z", folder: &folderD},
+		{path: "/Folder C/To Folder A", file: &fileX},
+		{path: "/Folder C/To Folder A/Folder B", err: ds.ErrNotFound},
+		{path: "/Folder B", err: ds.ErrNotFound},
+		{path: "/unknown/File Y", err: ds.ErrNotFound},
+	}
+
+	for _, tc := range tests {
+		file, folder, err := store.Lookup(ctx, drive.ID, tc.path)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%q: unexpected error: %v", tc.path, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(file, tc.file) {
+			t.Errorf("%q: file does not match: %v", tc.path, file)
+		}
+
+		if !reflect.DeepEqual(folder, tc.folder) {
+			t.Errorf("%q: folder does not match: %v", tc.path, folder)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package datastoretest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// A ShortcutSetupFunc returns an empty ShortcutResolver. It is called once for every test of the suite.
+type ShortcutSetupFunc func(t *testing.T) ds.ShortcutResolver
+
+// RunShortcuts runs the conformance test suite of the ShortcutResolver interface
+// against the ShortcutResolvers returned by setup.
+//
+// The paths of the targets are only compared when the ShortcutResolver is a Reader as well.
+func RunShortcuts(t *testing.T, setup ShortcutSetupFunc) {
+	other := ds.Drive{ID: "other", Name: "Other Shared Drive", PageToken: "1"}
+
+	folderA := ds.Folder{ID: "A", Name: "Folder A", Parent: "drive", Path: "/Folder A"}
+	fileZ := ds.File{ID: "Z", Name: "File Z", Parent: "A", Path: "/Folder A/File Z", MimeType: "image/png"}
+	fileV := ds.File{ID: "V", Name: "File V", Parent: "other", Path: "/File V", MimeType: "image/jpeg"}
+
+	tests := []struct {
+		name       string
+		shortcutID string
+		file       *ds.File
+		folder     *ds.Folder
+		err        error
+	}{
+		{name: "folder target", shortcutID: "Y", folder: &folderA},
+		{name: "file target", shortcutID: "X", file: &fileZ},
+		{name: "target in another Shared Drive", shortcutID: "W", file: &fileV},
+		{name: "unknown target", shortcutID: "U", err: ds.ErrNotFound},
+		{name: "not a shortcut", shortcutID: "Z", err: ds.ErrNotFound},
+		{name: "unknown shortcut", shortcutID: "unknown", err: ds.ErrNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := setup(t)
+
+			err := store.FullSync(drive,
+				[]ds.Folder{{ID: "A", Name: "Folder A", Parent: "drive"}},
+				[]ds.File{
+					{ID: "Z", Name: "File Z", Parent: "A", MimeType: "image/png"},
+					{ID: "Y", Name: "To Folder A", Parent: "drive", MimeType: "application/vnd.google-apps.shortcut",
+						Shortcut: &ds.Shortcut{TargetID: "A", TargetMimeType: "application/vnd.google-apps.folder"}},
+					{ID: "X", Name: "To File Z", Parent: "drive", MimeType: "application/vnd.google-apps.shortcut",
+						Shortcut: &ds.Shortcut{TargetID: "Z", TargetMimeType: "image/png"}},
+					{ID: "W", Name: "To File V", Parent: "drive", MimeType: "application/vnd.google-apps.shortcut",
+						Shortcut: &ds.Shortcut{TargetID: "V", TargetMimeType: "image/jpeg"}},
+					{ID: "U", Name: "To Nowhere", Parent: "drive", MimeType: "application/vnd.google-apps.shortcut",
+						Shortcut: &ds.Shortcut{TargetID: "unknown", TargetMimeType: "image/jpeg"}},
+				},
+			)
+
+			if err != nil {
+				t.Fatalf("Unexpected error at full sync: %v", err)
+			}
+
+			err = store.FullSync(other, nil, []ds.File{
+				{ID: "V", Name: "File V", Parent: "other", MimeType: "image/jpeg"},
+			})
+
+			if err != nil {
+				t.Fatalf("Unexpected error at full sync: %v", err)
+			}
+
+			file, folder, err := store.ResolveShortcut(context.Background(), drive.ID, tc.shortcutID)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			expectedFile, expectedFolder := tc.file, tc.folder
+
+			// Not every datastore maintains paths, so they are only compared for Readers.
+			if _, ok := store.(ds.Reader); !ok {
+				if expectedFile != nil {
+					f := *expectedFile
+					f.Path = ""
+					expectedFile = &f
+				}
+
+				if expectedFolder != nil {
+					f := *expectedFolder
+					f.Path = ""
+					expectedFolder = &f
+				}
+			}
+
+			if !reflect.DeepEqual(file, expectedFile) {
+				t.Log(file)
+				t.Log(expectedFile)
+				t.Error("File does not match the expected target")
+			}
+
+			if !reflect.DeepEqual(folder, expectedFolder) {
+				t.Log(folder)
+				t.Log(expectedFolder)
+				t.Error("Folder does not match the expected target")
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package memory
+
+import (
+	"testing"
+
+	ds "github.com/m-rots/bernard/datastore"
+	"github.com/m-rots/bernard/datastore/datastoretest"
+)
+
+func TestConformance(t *testing.T) {
+	datastoretest.Run(t, func(t *testing.T) ds.Datastore {
+		return New()
+	}, func(t *testing.T, store ds.Datastore, driveID string) ([]ds.Folder, []ds.File) {
+		return store.(*Datastore).Folders(driveID), store.(*Datastore).Files(driveID)
+	})
+}
+
+func TestReaderConformance(t *testing.T) {
+	datastoretest.RunReader(t, func(t *testing.T) ds.Reader {
+		return New()
+	})
+}
+
+func TestShortcutConformance(t *testing.T) {
+	datastoretest.RunShortcuts(t, func(t *testing.T) ds.ShortcutResolver {
+		return New()
+	})
+}
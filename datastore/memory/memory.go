@@ -0,0 +1,428 @@
+// Package memory provides an in-memory implementation of a Bernard datastore.
+//
+// The datastore does not persist anything, which makes it suitable for
+// unit tests and short-lived reference syncs. It follows the relationship
+// constraints of the reference SQLite datastore, so a sync which results in
+// a data anomaly in SQLite results in a data anomaly here as well.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// New returns an empty in-memory Bernard Datastore.
+func New() *Datastore {
+	return &Datastore{drives: make(map[string]*drive)}
+}
+
+// Datastore holds the state of all Shared Drives in memory
+// and implements the Bernard Datastore interface.
+//
+// The Datastore is safe for concurrent use.
+type Datastore struct {
+	mu     sync.RWMutex
+	drives map[string]*drive
+}
+
+// drive holds the state of a single Shared Drive.
+type drive struct {
+	pageToken string
+	folders   map[string]ds.Folder
+	files     map[string]ds.File
+}
+
+// A change applies folders and files to a drive in place,
+// while keeping the previous state of every touched item so the change can be undone.
+//
+// A nil value indicates the item did not exist before the change.
+type change struct {
+	d       *drive
+	folders map[string]*ds.Folder
+	files   map[string]*ds.File
+}
+
+func newChange(d *drive) *change {
+	return &change{d: d, folders: make(map[string]*ds.Folder), files: make(map[string]*ds.File)}
+}
+
+func (c *change) setFolder(f ds.Folder) {
+	c.keepFolder(f.ID)
+	c.d.folders[f.ID] = f
+}
+
+func (c *change) setFile(f ds.File) {
+	c.keepFile(f.ID)
+	c.d.files[f.ID] = f
+}
+
+func (c *change) removeFolder(id string) {
+	c.keepFolder(id)
+	delete(c.d.folders, id)
+}
+
+func (c *change) removeFile(id string) {
+	c.keepFile(id)
+	delete(c.d.files, id)
+}
+
+// keepFolder keeps the state of the folder before it is first touched by the change.
+func (c *change) keepFolder(id string) {
+	if _, ok := c.folders[id]; ok {
+		return
+	}
+
+	if f, ok := c.d.folders[id]; ok {
+		c.folders[id] = &f
+	} else {
+		c.folders[id] = nil
+	}
+}
+
+// keepFile keeps the state of the file before it is first touched by the change.
+func (c *change) keepFile(id string) {
+	if _, ok := c.files[id]; ok {
+		return
+	}
+
+	if f, ok := c.d.files[id]; ok {
+		c.files[id] = &f
+	} else {
+		c.files[id] = nil
+	}
+}
+
+// undo restores the previous state of every touched item.
+func (c *change) undo() {
+	for id, f := range c.folders {
+		if f == nil {
+			delete(c.d.folders, id)
+		} else {
+			c.d.folders[id] = *f
+		}
+	}
+
+	for id, f := range c.files {
+		if f == nil {
+			delete(c.d.files, id)
+		} else {
+			c.d.files[id] = *f
+		}
+	}
+}
+
+// FullSync synchronises the provided Drive state to the datastore.
+func (store *Datastore) FullSync(drive ds.Drive, folders []ds.Folder, files []ds.File) error {
+	return store.FullSyncContext(context.Background(), drive, folders, files)
+}
+
+// FullSyncContext synchronises the provided Drive state to the datastore.
+//
+// The relationship constraints are checked once all folders and files are written.
+// Nothing is written when the context is cancelled.
+func (store *Datastore) FullSyncContext(ctx context.Context, drive ds.Drive, folders []ds.Folder, files []ds.File) error {
+	// Insert the Shared Drive as the root folder.
+	root := ds.Folder{ID: drive.ID, Name: drive.Name}
+	return store.write(ctx, drive, append([]ds.Folder{root}, folders...), files, nil)
+}
+
+// PartialSync synchronises the provided changes to the datastore.
+func (store *Datastore) PartialSync(drive ds.Drive, changedFolders []ds.Folder, changedFiles []ds.File, removedIDs []string) error {
+	return store.PartialSyncContext(context.Background(), drive, changedFolders, changedFiles, removedIDs)
+}
+
+// PartialSyncContext synchronises the provided changes to the datastore.
+//
+// 1. Update the pageToken and (if applicable) the name of the Shared Drive.
+//
+// 2. Upsert the changed folders and files.
+//
+// 3. Remove any items of which the IDs match with the removedIDs slice.
+//
+// Like the reference SQLite datastore, removing a folder which still has children
+// results in a data anomaly, while it is removed from the additional parents of other items.
+// Nothing is written when the context is cancelled.
+func (store *Datastore) PartialSyncContext(ctx context.Context, drive ds.Drive, changedFolders []ds.Folder, changedFiles []ds.File, removedIDs []string) error {
+	// Drive name is empty if not changed, so when not empty we should update it.
+	if drive.Name != "" {
+		root := ds.Folder{ID: drive.ID, Name: drive.Name}
+		changedFolders = append([]ds.Folder{root}, changedFolders...)
+	}
+
+	return store.write(ctx, drive, changedFolders, changedFiles, removedIDs)
+}
+
+// write applies the changes to the Shared Drive in place,
+// and undoes them when the relationship constraints are not met.
+//
+// Only the touched items are kept for the undo, so the cost of a write
+// does not depend on the size of the Shared Drive, unless folders are removed.
+func (store *Datastore) write(ctx context.Context, d ds.Drive, folders []ds.Folder, files []ds.File, removedIDs []string) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	current, exists := store.drives[d.ID]
+	if !exists {
+		current = &drive{folders: make(map[string]ds.Folder), files: make(map[string]ds.File)}
+	}
+
+	c := newChange(current)
+	defer func() {
+		if err != nil {
+			c.undo()
+		}
+	}()
+
+	for _, f := range folders {
+		c.setFolder(copyFolder(f))
+	}
+
+	for _, f := range files {
+		c.setFile(copyFile(f))
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	removed := make(map[string]bool, len(removedIDs))
+	for _, id := range removedIDs {
+		if _, ok := current.folders[id]; ok {
+			removed[id] = true
+			c.removeFolder(id)
+		}
+
+		if _, ok := current.files[id]; ok {
+			c.removeFile(id)
+		}
+	}
+
+	if len(removed) > 0 {
+		if err := c.unlink(removed); err != nil {
+			return err
+		}
+	}
+
+	if err := c.check(); err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// Update the pageToken for future sync jobs.
+	current.pageToken = d.PageToken
+	store.drives[d.ID] = current
+	return nil
+}
+
+// unlink removes the removed folders from the additional parents of all items.
+//
+// Like the reference SQLite datastore, ErrDataAnomaly is returned
+// when a removed folder still is the parent of an item.
+func (c *change) unlink(removed map[string]bool) error {
+	for id, f := range c.d.folders {
+		if removed[f.Parent] {
+			return fmt.Errorf("%v parent %v: %w", id, f.Parent, ds.ErrDataAnomaly)
+		}
+
+		if parents, changed := without(f.AdditionalParents, removed); changed {
+			f.AdditionalParents = parents
+			c.setFolder(f)
+		}
+	}
+
+	for id, f := range c.d.files {
+		if removed[f.Parent] {
+			return fmt.Errorf("%v parent %v: %w", id, f.Parent, ds.ErrDataAnomaly)
+		}
+
+		if parents, changed := without(f.AdditionalParents, removed); changed {
+			f.AdditionalParents = parents
+			c.setFile(f)
+		}
+	}
+
+	return nil
+}
+
+// without returns the parents which have not been removed, or nil when no parents remain.
+func without(parents []string, removed map[string]bool) (remaining []string, changed bool) {
+	for _, p := range parents {
+		if removed[p] {
+			changed = true
+			continue
+		}
+
+		remaining = append(remaining, p)
+	}
+
+	if !changed {
+		return parents, false
+	}
+
+	return remaining, true
+}
+
+// check returns ErrDataAnomaly when the parent or any of the additional parents
+// of a touched folder or file does not exist.
+//
+// The root folder is the only folder without a parent.
+func (c *change) check() error {
+	for id := range c.folders {
+		f, ok := c.d.folders[id]
+		if !ok {
+			continue
+		}
+
+		if f.Parent != "" {
+			if err := c.d.checkParent(id, f.Parent); err != nil {
+				return err
+			}
+		}
+
+		for _, p := range f.AdditionalParents {
+			if err := c.d.checkParent(id, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	for id := range c.files {
+		f, ok := c.d.files[id]
+		if !ok {
+			continue
+		}
+
+		if err := c.d.checkParent(id, f.Parent); err != nil {
+			return err
+		}
+
+		for _, p := range f.AdditionalParents {
+			if err := c.d.checkParent(id, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkParent returns ErrDataAnomaly when the parent of the item does not exist.
+func (d *drive) checkParent(id, parent string) error {
+	if _, ok := d.folders[parent]; !ok {
+		return fmt.Errorf("%v parent %v: %w", id, parent, ds.ErrDataAnomaly)
+	}
+
+	return nil
+}
+
+// PageToken retrieves the pageToken the datastore currently reflects.
+func (store *Datastore) PageToken(driveID string) (string, error) {
+	return store.PageTokenContext(context.Background(), driveID)
+}
+
+// PageTokenContext retrieves the pageToken the datastore currently reflects.
+func (store *Datastore) PageTokenContext(ctx context.Context, driveID string) (string, error) {
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	d, ok := store.drives[driveID]
+	if !ok {
+		return "", ds.ErrFullSync
+	}
+
+	return d.pageToken, nil
+}
+
+// Folders returns all folders of the Shared Drive, including the root folder,
+// sorted on ID.
+func (store *Datastore) Folders(driveID string) []ds.Folder {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	d, ok := store.drives[driveID]
+	if !ok {
+		return nil
+	}
+
+	var folders []ds.Folder
+	for _, f := range d.folders {
+		folders = append(folders, copyFolder(f))
+	}
+
+	sort.Slice(folders, func(i, j int) bool { return folders[i].ID < folders[j].ID })
+	return folders
+}
+
+// Files returns all files of the Shared Drive, sorted on ID.
+func (store *Datastore) Files(driveID string) []ds.File {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	d, ok := store.drives[driveID]
+	if !ok {
+		return nil
+	}
+
+	var files []ds.File
+	for _, f := range d.files {
+		files = append(files, copyFile(f))
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+	return files
+}
+
+// copyFolder returns a deep copy of the folder,
+// so the caller can not modify the state of the datastore.
+func copyFolder(f ds.Folder) ds.Folder {
+	f.AdditionalParents = copyStrings(f.AdditionalParents)
+	f.Extra = copyExtra(f.Extra)
+	return f
+}
+
+// copyFile returns a deep copy of the file,
+// so the caller can not modify the state of the datastore.
+func copyFile(f ds.File) ds.File {
+	f.AdditionalParents = copyStrings(f.AdditionalParents)
+	f.Extra = copyExtra(f.Extra)
+
+	if f.Shortcut != nil {
+		shortcut := *f.Shortcut
+		f.Shortcut = &shortcut
+	}
+
+	return f
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+
+	return append([]string(nil), s...)
+}
+
+func copyExtra(extra map[string]json.RawMessage) map[string]json.RawMessage {
+	if extra == nil {
+		return nil
+	}
+
+	c := make(map[string]json.RawMessage, len(extra))
+	for key, value := range extra {
+		c[key] = append(json.RawMessage(nil), value...)
+	}
+
+	return c
+}
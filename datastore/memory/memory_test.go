@@ -0,0 +1,324 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+func TestFullSync(t *testing.T) {
+	type Given struct {
+		folders []ds.Folder
+		files   []ds.File
+	}
+
+	type Expected struct {
+		err     error
+		folders []ds.Folder
+		files   []ds.File
+	}
+
+	type test struct {
+		name     string
+		given    Given
+		expected Expected
+	}
+
+	drive := ds.Drive{ID: "drive", Name: "Shared Drive", PageToken: "1"}
+
+	var testCases = []test{
+		{
+			name: "invalid file parent -> data anomaly",
+			given: Given{
+				files: []ds.File{{ID: "Z", Parent: "unknown"}},
+			},
+			expected: Expected{err: ds.ErrDataAnomaly},
+		},
+		{
+			name: "invalid folder parent -> data anomaly",
+			given: Given{
+				folders: []ds.Folder{{ID: "A", Parent: "unknown"}},
+			},
+			expected: Expected{err: ds.ErrDataAnomaly},
+		},
+		{
+			name: "invalid additional parent -> data anomaly",
+			given: Given{
+				files: []ds.File{{ID: "Z", Parent: "drive", AdditionalParents: []string{"unknown"}}},
+			},
+			expected: Expected{err: ds.ErrDataAnomaly},
+		},
+		{
+			name: "children before their parents",
+			given: Given{
+				folders: []ds.Folder{
+					{ID: "B", Name: "Folder B", Parent: "A"},
+					{ID: "A", Name: "Folder A", Parent: "drive"},
+				},
+				files: []ds.File{
+					{ID: "Z", Name: "File Z", Parent: "B", AdditionalParents: []string{"A"}, MD5: "ZZZ", Size: 10},
+					{ID: "Y", Name: "File Y", Parent: "drive", MD5: "YYY", Size: 20},
+				},
+			},
+			expected: Expected{
+				folders: []ds.Folder{
+					{ID: "A", Name: "Folder A", Parent: "drive"},
+					{ID: "B", Name: "Folder B", Parent: "A"},
+					{ID: "drive", Name: "Shared Drive"},
+				},
+				files: []ds.File{
+					{ID: "Y", Name: "File Y", Parent: "drive", MD5: "YYY", Size: 20},
+					{ID: "Z", Name: "File Z", Parent: "B", AdditionalParents: []string{"A"}, MD5: "ZZZ", Size: 10},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := New()
+
+			err := store.FullSync(drive, tc.given.folders, tc.given.files)
+			if !errors.Is(err, tc.expected.err) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if folders := store.Folders(drive.ID); !reflect.DeepEqual(folders, tc.expected.folders) {
+				t.Log(folders)
+				t.Log(tc.expected.folders)
+				t.Error("Folders do not match")
+			}
+
+			if files := store.Files(drive.ID); !reflect.DeepEqual(files, tc.expected.files) {
+				t.Log(files)
+				t.Log(tc.expected.files)
+				t.Error("Files do not match")
+			}
+
+			// A failed full sync should not leave a pageToken behind.
+			if tc.expected.err != nil {
+				if _, err := store.PageToken(drive.ID); !errors.Is(err, ds.ErrFullSync) {
+					t.Errorf("Expected ErrFullSync, got: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestPartialSync(t *testing.T) {
+	drive := ds.Drive{ID: "drive", Name: "Shared Drive", PageToken: "1"}
+
+	type Changes struct {
+		folders []ds.Folder
+		files   []ds.File
+		removed []string
+	}
+
+	type Expected struct {
+		err     error
+		folders []ds.Folder
+		files   []ds.File
+	}
+
+	type test struct {
+		name     string
+		changes  Changes
+		expected Expected
+	}
+
+	var testCases = []test{
+		{
+			name: "move files and folders",
+			changes: Changes{
+				folders: []ds.Folder{{ID: "B", Name: "Folder B", Parent: "drive"}},
+				files:   []ds.File{{ID: "Z", Name: "File Z", Parent: "A"}},
+			},
+			expected: Expected{
+				folders: []ds.Folder{
+					{ID: "A", Name: "Folder A", Parent: "drive"},
+					{ID: "B", Name: "Folder B", Parent: "drive"},
+					{ID: "drive", Name: "Shared Drive"},
+				},
+				files: []ds.File{
+					{ID: "X", Name: "File X", Parent: "drive", AdditionalParents: []string{"B"}},
+					{ID: "Y", Name: "File Y", Parent: "B"},
+					{ID: "Z", Name: "File Z", Parent: "A"},
+				},
+			},
+		},
+		{
+			name: "remove folder with all its children",
+			changes: Changes{
+				removed: []string{"A", "B", "Y"},
+			},
+			expected: Expected{
+				folders: []ds.Folder{
+					{ID: "drive", Name: "Shared Drive"},
+				},
+				files: []ds.File{
+					{ID: "X", Name: "File X", Parent: "drive"},
+					{ID: "Z", Name: "File Z", Parent: "drive"},
+				},
+			},
+		},
+		{
+			name: "remove folder which still has children -> data anomaly",
+			changes: Changes{
+				removed: []string{"B"},
+			},
+			expected: Expected{err: ds.ErrDataAnomaly},
+		},
+		{
+			name: "undo every change after a data anomaly",
+			changes: Changes{
+				folders: []ds.Folder{{ID: "A", Name: "Renamed A", Parent: "drive"}},
+				files:   []ds.File{{ID: "W", Name: "File W", Parent: "A"}},
+				removed: []string{"X", "B"},
+			},
+			expected: Expected{err: ds.ErrDataAnomaly},
+		},
+		{
+			name: "move into unknown folder -> data anomaly",
+			changes: Changes{
+				files: []ds.File{{ID: "Z", Name: "File Z", Parent: "unknown"}},
+			},
+			expected: Expected{err: ds.ErrDataAnomaly},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := New()
+
+			err := store.FullSync(drive,
+				[]ds.Folder{
+					{ID: "A", Name: "Folder A", Parent: "drive"},
+					{ID: "B", Name: "Folder B", Parent: "A"},
+				},
+				[]ds.File{
+					{ID: "Z", Name: "File Z", Parent: "drive"},
+					{ID: "Y", Name: "File Y", Parent: "B"},
+					{ID: "X", Name: "File X", Parent: "drive", AdditionalParents: []string{"B"}},
+				},
+			)
+
+			if err != nil {
+				t.Fatalf("Unexpected error at full sync: %s", err.Error())
+			}
+
+			folders, files := store.Folders(drive.ID), store.Files(drive.ID)
+
+			changes := ds.Drive{ID: drive.ID, PageToken: "2"}
+			err = store.PartialSync(changes, tc.changes.folders, tc.changes.files, tc.changes.removed)
+			if !errors.Is(err, tc.expected.err) {
+				t.Fatalf("Unexpected error at partial sync: %v", err)
+			}
+
+			pageToken, _ := store.PageToken(drive.ID)
+
+			if tc.expected.err != nil {
+				if pageToken != drive.PageToken {
+					t.Errorf("pageToken should not be updated after an error, got: %s", pageToken)
+				}
+
+				if !reflect.DeepEqual(store.Folders(drive.ID), folders) {
+					t.Error("Folders should not be updated after an error")
+				}
+
+				if !reflect.DeepEqual(store.Files(drive.ID), files) {
+					t.Error("Files should not be updated after an error")
+				}
+
+				return
+			}
+
+			if pageToken != changes.PageToken {
+				t.Errorf("pageToken was not updated, got: %s", pageToken)
+			}
+
+			if folders := store.Folders(drive.ID); !reflect.DeepEqual(folders, tc.expected.folders) {
+				t.Log(folders)
+				t.Log(tc.expected.folders)
+				t.Error("Folders do not match")
+			}
+
+			if files := store.Files(drive.ID); !reflect.DeepEqual(files, tc.expected.files) {
+				t.Log(files)
+				t.Log(tc.expected.files)
+				t.Error("Files do not match")
+			}
+		})
+	}
+}
+
+func TestCopies(t *testing.T) {
+	store := New()
+
+	parents := []string{"drive"}
+	err := store.FullSync(ds.Drive{ID: "drive", PageToken: "1"},
+		[]ds.Folder{{ID: "A", Name: "Folder A", Parent: "drive"}},
+		[]ds.File{{ID: "Z", Name: "File Z", Parent: "A", AdditionalParents: parents}},
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error at full sync: %s", err.Error())
+	}
+
+	// Neither the provided nor the returned files should share memory with the datastore.
+	parents[0] = "modified"
+	store.Files("drive")[0].AdditionalParents[0] = "modified"
+
+	if parent := store.Files("drive")[0].AdditionalParents[0]; parent != "drive" {
+		t.Errorf("Datastore was modified through a slice: %s", parent)
+	}
+}
+
+func TestConcurrentSync(t *testing.T) {
+	store := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			drive := ds.Drive{ID: fmt.Sprintf("drive %d", i%2), PageToken: fmt.Sprint(i)}
+			files := []ds.File{{ID: fmt.Sprint(i), Parent: drive.ID}}
+
+			if err := store.FullSync(drive, nil, files); err != nil {
+				t.Errorf("Unexpected error at full sync: %s", err.Error())
+			}
+
+			store.PageToken(drive.ID)
+			store.Files(drive.ID)
+		}(i)
+	}
+
+	wg.Wait()
+
+	if files := len(store.Files("drive 0")) + len(store.Files("drive 1")); files != 10 {
+		t.Errorf("Expected 10 files, got: %d", files)
+	}
+}
+
+func TestCancelledSync(t *testing.T) {
+	store := New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := store.FullSyncContext(ctx, ds.Drive{ID: "drive", PageToken: "1"}, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+
+	if _, err := store.PageToken("drive"); !errors.Is(err, ds.ErrFullSync) {
+		t.Errorf("Expected ErrFullSync, got: %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// GetFile returns the file with the provided ID within the Shared Drive of the driveID.
+func (store *Datastore) GetFile(ctx context.Context, driveID string, id string) (*ds.File, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	d, ok := store.drives[driveID]
+	if !ok {
+		return nil, fmt.Errorf("%v: %w", id, ds.ErrNotFound)
+	}
+
+	f, ok := d.files[id]
+	if !ok {
+		return nil, fmt.Errorf("%v: %w", id, ds.ErrNotFound)
+	}
+
+	file := copyFile(f)
+	file.Path = d.path(file.Parent, file.Name)
+	return &file, nil
+}
+
+// GetFolder returns the folder with the provided ID within the Shared Drive of the driveID.
+func (store *Datastore) GetFolder(ctx context.Context, driveID string, id string) (*ds.Folder, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	d, ok := store.drives[driveID]
+	if !ok {
+		return nil, fmt.Errorf("%v: %w", id, ds.ErrNotFound)
+	}
+
+	f, ok := d.folders[id]
+	if !ok {
+		return nil, fmt.Errorf("%v: %w", id, ds.ErrNotFound)
+	}
+
+	folder := copyFolder(f)
+	folder.Path = d.path(folder.Parent, folder.Name)
+	return &folder, nil
+}
+
+// ListChildren returns the folders and files within the folder, sorted on name and ID.
+// Items of which the folder is one of the additional parents are included as well.
+func (store *Datastore) ListChildren(ctx context.Context, driveID string, folderID string) (folders []ds.Folder, files []ds.File, err error) {
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	d, ok := store.drives[driveID]
+	if !ok {
+		return nil, nil, fmt.Errorf("%v: %w", folderID, ds.ErrNotFound)
+	}
+
+	if _, ok := d.folders[folderID]; !ok {
+		return nil, nil, fmt.Errorf("%v: %w", folderID, ds.ErrNotFound)
+	}
+
+	for _, f := range d.folders {
+		if isChild(folderID, f.Parent, f.AdditionalParents) {
+			folder := copyFolder(f)
+			folder.Path = d.path(f.Parent, f.Name)
+			folders = append(folders, folder)
+		}
+	}
+
+	for _, f := range d.files {
+		if isChild(folderID, f.Parent, f.AdditionalParents) {
+			file := copyFile(f)
+			file.Path = d.path(f.Parent, f.Name)
+			files = append(files, file)
+		}
+	}
+
+	sort.Slice(folders, func(i, j int) bool {
+		return byName(folders[i].Name, folders[i].ID, folders[j].Name, folders[j].ID)
+	})
+
+	sort.Slice(files, func(i, j int) bool {
+		return byName(files[i].Name, files[i].ID, files[j].Name, files[j].ID)
+	})
+
+	return folders, files, nil
+}
+
+// Lookup returns the file or folder at the slash-separated path,
+// relative to the root of the Shared Drive.
+//
+// Folders take precedence over files with the same name,
+// after which the item with the lowest ID is picked.
+func (store *Datastore) Lookup(ctx context.Context, driveID string, path string) (*ds.File, *ds.Folder, error) {
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	d, ok := store.drives[driveID]
+	if !ok {
+		return nil, nil, fmt.Errorf("%v: %w", path, ds.ErrNotFound)
+	}
+
+	names := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	folder := d.folders[driveID]
+
+	for i, name := range names {
+		if child, ok := d.child(folder.ID, name); ok {
+			folder = child
+			continue
+		}
+
+		// Only the last name of the path can point to a file.
+		if i == len(names)-1 {
+			var file *ds.File
+			for _, f := range d.files {
+				if f.Name == name && isChild(folder.ID, f.Parent, f.AdditionalParents) && (file == nil || f.ID < file.ID) {
+					f := f
+					file = &f
+				}
+			}
+
+			if file != nil {
+				f := copyFile(*file)
+				f.Path = d.path(f.Parent, f.Name)
+				return &f, nil, nil
+			}
+		}
+
+		return nil, nil, fmt.Errorf("%v: %w", path, ds.ErrNotFound)
+	}
+
+	f := copyFolder(folder)
+	f.Path = d.path(f.Parent, f.Name)
+	return nil, &f, nil
+}
+
+// path returns the path of an item with the provided parent and name,
+// following the primary parents up to the root folder.
+func (d *drive) path(parent string, name string) string {
+	// The root folder is the only folder without a parent.
+	if parent == "" {
+		return "/"
+	}
+
+	names := []string{name}
+
+	// The number of folders limits the walk, should the parents form a cycle.
+	for i := 0; i < len(d.folders); i++ {
+		folder, ok := d.folders[parent]
+		if !ok || folder.Parent == "" {
+			break
+		}
+
+		names = append(names, folder.Name)
+		parent = folder.Parent
+	}
+
+	var path strings.Builder
+	for i := len(names) - 1; i >= 0; i-- {
+		path.WriteString("/")
+		path.WriteString(names[i])
+	}
+
+	return path.String()
+}
+
+// child returns the child folder with the provided name and the lowest ID.
+func (d *drive) child(parent string, name string) (child ds.Folder, ok bool) {
+	for _, f := range d.folders {
+		if f.Name == name && isChild(parent, f.Parent, f.AdditionalParents) && (!ok || f.ID < child.ID) {
+			child, ok = f, true
+		}
+	}
+
+	return child, ok
+}
+
+// isChild reports whether the folder is the parent or one of the additional parents.
+func isChild(folderID string, parent string, additionalParents []string) bool {
+	if parent == folderID {
+		return true
+	}
+
+	for _, p := range additionalParents {
+		if p == folderID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// byName reports whether the item a sorts before item b.
+func byName(nameA, idA, nameB, idB string) bool {
+	if nameA != nameB {
+		return nameA < nameB
+	}
+
+	return idA < idB
+}
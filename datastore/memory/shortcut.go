@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// ResolveShortcut returns the target of the shortcut with the provided ID.
+//
+// As a shortcut can point to a file or folder in another Shared Drive,
+// the target is looked up in all Shared Drives present in the datastore.
+// The Shared Drive of the shortcut takes precedence.
+// The path of the target is relative to the root of its own Shared Drive.
+func (store *Datastore) ResolveShortcut(ctx context.Context, driveID string, shortcutID string) (*ds.File, *ds.Folder, error) {
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	d, ok := store.drives[driveID]
+	if !ok {
+		return nil, nil, fmt.Errorf("%v: %w", shortcutID, ds.ErrNotFound)
+	}
+
+	shortcut, ok := d.files[shortcutID]
+	if !ok || shortcut.Shortcut == nil {
+		return nil, nil, fmt.Errorf("%v is not a shortcut: %w", shortcutID, ds.ErrNotFound)
+	}
+
+	targetID := shortcut.Shortcut.TargetID
+
+	// Look in the Shared Drive of the shortcut first.
+	drives := []*drive{d}
+	for id, other := range store.drives {
+		if id != driveID {
+			drives = append(drives, other)
+		}
+	}
+
+	for _, d := range drives {
+		if f, ok := d.files[targetID]; ok {
+			file := copyFile(f)
+			file.Path = d.path(file.Parent, file.Name)
+			return &file, nil, nil
+		}
+
+		// The root folder of a Shared Drive is not a valid target.
+		if f, ok := d.folders[targetID]; ok && f.Parent != "" {
+			folder := copyFolder(f)
+			folder.Path = d.path(folder.Parent, folder.Name)
+			return nil, &folder, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("%v: %w", targetID, ds.ErrNotFound)
+}
@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"testing"
+
+	ds "github.com/m-rots/bernard/datastore"
+	"github.com/m-rots/bernard/datastore/datastoretest"
+)
+
+func TestConformance(t *testing.T) {
+	datastoretest.Run(t, func(t *testing.T) ds.Datastore {
+		return setupTest(t)
+	}, listItems)
+}
+
+// listItems returns all folders and files of the Drive sorted on ID.
+// The IDs are sorted bytewise, regardless of the collation of the database.
+func listItems(t *testing.T, store ds.Datastore, driveID string) (folders []ds.Folder, files []ds.File) {
+	t.Helper()
+
+	db := store.(*Datastore).DB
+
+	getParents, err := db.Prepare(sqlGetAdditionalParents)
+	if err != nil {
+		t.Fatalf("Could not prepare statement: %s", err.Error())
+	}
+
+	defer getParents.Close()
+
+	rows, err := db.Query(`SELECT id FROM folder WHERE drive=$1 ORDER BY id COLLATE "C"`, driveID)
+	if err != nil {
+		t.Fatalf("Could not query folder rows: %s", err.Error())
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		f := ds.Folder{}
+
+		if err = rows.Scan(&f.ID); err != nil {
+			t.Fatalf("Error when scanning folder rows: %s", err.Error())
+		}
+
+		err = scanFolder(db.QueryRow(sqlGetFolderByID, f.ID, driveID), getParents, driveID, &f)
+		if err != nil {
+			t.Fatalf("Error when scanning folder: %s", err.Error())
+		}
+
+		folders = append(folders, f)
+	}
+
+	rows, err = db.Query(`SELECT id FROM file WHERE drive=$1 ORDER BY id COLLATE "C"`, driveID)
+	if err != nil {
+		t.Fatalf("Could not query file rows: %s", err.Error())
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		f := ds.File{}
+
+		if err = rows.Scan(&f.ID); err != nil {
+			t.Fatalf("Error when scanning file rows: %s", err.Error())
+		}
+
+		err = scanFile(db.QueryRow(sqlGetFileByID, f.ID, driveID), getParents, driveID, &f)
+		if err != nil {
+			t.Fatalf("Error when scanning file: %s", err.Error())
+		}
+
+		files = append(files, f)
+	}
+
+	return folders, files
+}
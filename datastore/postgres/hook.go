@@ -0,0 +1,237 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/m-rots/bernard"
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// The Difference contains all added, changes and removed files and folders
+// between two states.
+type Difference struct {
+	AddedFiles   []ds.File
+	ChangedFiles []FileDifference
+	RemovedFiles []ds.File
+
+	AddedFolders   []ds.Folder
+	ChangedFolders []FolderDifference
+	RemovedFolders []ds.Folder
+}
+
+// A FolderDifference holds the old and new state of a changed folder.
+type FolderDifference struct {
+	Old ds.Folder
+	New ds.Folder
+}
+
+// A FileDifference holds the old and new state of a changed file.
+type FileDifference struct {
+	Old ds.File
+	New ds.File
+}
+
+// NewDifferencesHook creates a Hook which checks which files and folders
+// have been added, changed or removed.
+//
+// Like all hooks, the corresponding output struct is only updated
+// when the hook is executed. Every execution replaces the Difference of the
+// previous one, so a hook used by Watch or a PushReceiver only holds the
+// differences of the latest partial sync.
+//
+// The hook and its Difference are not safe for concurrent use.
+// When multiple Shared Drives are watched, the hooks run concurrently,
+// so every Shared Drive needs its own Watch and hook.
+func (store *Datastore) NewDifferencesHook() (bernard.Hook, *Difference) {
+	var diff Difference
+
+	hook := func(drive ds.Drive, files []ds.File, folders []ds.Folder, removed []string) error {
+		diff = Difference{}
+
+		// prepare the `sqlGetAdditionalParents` statement for better performance
+		getParents, err := store.DB.Prepare(sqlGetAdditionalParents)
+		if err != nil {
+			return fmt.Errorf("%v: %w", sqlGetAdditionalParents, ErrInvalidStatement)
+		}
+
+		defer getParents.Close()
+
+		// prepare the `sqlGetFolderByID` statement for better performance
+		getFolder, err := store.DB.Prepare(sqlGetFolderByID)
+		if err != nil {
+			return fmt.Errorf("%v: %w", sqlGetFolderByID, ErrInvalidStatement)
+		}
+
+		defer getFolder.Close()
+
+		for _, folder := range folders {
+			f := ds.Folder{ID: folder.ID}
+			row := getFolder.QueryRow(folder.ID, drive.ID)
+			err := scanFolder(row, getParents, drive.ID, &f)
+			if err != nil {
+				// If no row is returned, the folder does not yet exist in the old state.
+				// Therefore, this folder must have been added.
+				if errors.Is(err, sql.ErrNoRows) {
+					diff.AddedFolders = append(diff.AddedFolders, folder)
+					continue
+				}
+
+				return fmt.Errorf("folder scan err in hook: %w", ds.ErrDatabase)
+			}
+
+			// If any of the fields do not align between the old and new state,
+			// then this folder must have been changed.
+			if ds.FolderChanged(f, folder) {
+				diff.ChangedFolders = append(diff.ChangedFolders, FolderDifference{Old: f, New: folder})
+			}
+		}
+
+		// prepare the `sqlGetFileByID` statement for better performance
+		getFile, err := store.DB.Prepare(sqlGetFileByID)
+		if err != nil {
+			return fmt.Errorf("%v: %w", sqlGetFileByID, ErrInvalidStatement)
+		}
+
+		defer getFile.Close()
+
+		for _, file := range files {
+			f := ds.File{ID: file.ID}
+			row := getFile.QueryRow(file.ID, drive.ID)
+			err := scanFile(row, getParents, drive.ID, &f)
+			if err != nil {
+				// If no row is returned, the file does not yet exist in the old state.
+				// Therefore, this file must have been added.
+				if errors.Is(err, sql.ErrNoRows) {
+					diff.AddedFiles = append(diff.AddedFiles, file)
+					continue
+				}
+
+				return fmt.Errorf("file scan err in hook: %w", ds.ErrDatabase)
+			}
+
+			// If any of the fields do not align between the old and new state,
+			// then this file must have been changed.
+			if ds.FileChanged(f, file) {
+				diff.ChangedFiles = append(diff.ChangedFiles, FileDifference{Old: f, New: file})
+			}
+		}
+
+		for _, id := range removed {
+			// check whether it could be a file first
+			file := ds.File{ID: id}
+			fileRow := getFile.QueryRow(id, drive.ID)
+			err := scanFile(fileRow, getParents, drive.ID, &file)
+
+			// no error -> thus a file
+			if err == nil {
+				diff.RemovedFiles = append(diff.RemovedFiles, file)
+				continue
+			}
+
+			if !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("removed file scan err in hook: %w", ds.ErrDatabase)
+			}
+
+			// file did not return, try again for folder
+			folder := ds.Folder{ID: id}
+			folderRow := getFolder.QueryRow(id, drive.ID)
+			err = scanFolder(folderRow, getParents, drive.ID, &folder)
+
+			// no error -> thus a folder
+			if err == nil {
+				diff.RemovedFolders = append(diff.RemovedFolders, folder)
+				continue
+			}
+
+			// we should not get a ErrNoRows when we have checked both the file and folder
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("ID does not exist from hook: %w", ds.ErrDataAnomaly)
+			}
+
+			// for some reason we could not scan the values of the folder
+			return fmt.Errorf("removed folder scan err in hook: %w", ds.ErrDatabase)
+		}
+
+		return nil
+	}
+
+	return hook, &diff
+}
+
+// scanFile scans a row of sqlGetFileByID and the additional parents into the provided file.
+//
+// The timestamps are converted to UTC, as lib/pq returns them in the time zone of the session.
+func scanFile(row *sql.Row, getParents *sql.Stmt, driveID string, f *ds.File) (err error) {
+	var targetID, targetMimeType sql.NullString
+
+	err = row.Scan(&f.Name, &f.Parent, &f.Trashed, &f.Size, &f.MD5, &f.SHA1, &f.SHA256,
+		&f.MimeType, &f.CreatedTime, &f.ModifiedTime, &f.Version,
+		&f.FileExtension, &f.OriginalFilename, &f.HeadRevisionID, (*extraJSON)(&f.Extra),
+		&targetID, &targetMimeType)
+	if err != nil {
+		return err
+	}
+
+	f.CreatedTime = f.CreatedTime.UTC()
+	f.ModifiedTime = f.ModifiedTime.UTC()
+
+	if targetID.Valid {
+		f.Shortcut = &ds.Shortcut{TargetID: targetID.String, TargetMimeType: targetMimeType.String}
+	}
+
+	f.AdditionalParents, err = scanParents(getParents, driveID, f.ID)
+	return err
+}
+
+// scanFolder scans a row of sqlGetFolderByID and the additional parents into the provided folder.
+//
+// The parent of the root folder is NULL and is scanned as an empty string.
+func scanFolder(row *sql.Row, getParents *sql.Stmt, driveID string, f *ds.Folder) (err error) {
+	var parent sql.NullString
+
+	err = row.Scan(&f.Name, &parent, &f.Trashed, (*extraJSON)(&f.Extra))
+	if err != nil {
+		return err
+	}
+
+	f.Parent = parent.String
+	f.AdditionalParents, err = scanParents(getParents, driveID, f.ID)
+	return err
+}
+
+// scanParents returns the additional parents of an item, or nil when it does not have any.
+func scanParents(getParents *sql.Stmt, driveID, id string) (parents []string, err error) {
+	rows, err := getParents.Query(id, driveID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var parent string
+		if err = rows.Scan(&parent); err != nil {
+			return nil, err
+		}
+
+		parents = append(parents, parent)
+	}
+
+	return parents, rows.Err()
+}
+
+const sqlGetFileByID = `
+SELECT name, parent, trashed, size, md5, sha1, sha256, mime_type, created_time, modified_time,
+	version, file_extension, original_filename, head_revision_id, extra,
+	shortcut_target_id, shortcut_target_mime_type
+FROM file WHERE id=$1 AND drive=$2
+`
+
+const sqlGetFolderByID = `
+SELECT name, parent, trashed, extra FROM folder WHERE id=$1 AND drive=$2
+`
+
+const sqlGetAdditionalParents = `
+SELECT parent FROM additional_parent WHERE id=$1 AND drive=$2 ORDER BY position
+`
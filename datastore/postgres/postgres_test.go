@@ -0,0 +1,284 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// setupTest connects to the PostgreSQL database of the BERNARD_POSTGRES_DSN
+// environment variable and empties all tables.
+// The test is skipped when the environment variable is not set.
+func setupTest(t *testing.T) *Datastore {
+	t.Helper()
+
+	dsn := os.Getenv("BERNARD_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("BERNARD_POSTGRES_DSN is not set")
+	}
+
+	datastore, err := New(dsn)
+	if err != nil {
+		t.Fatalf("Could not create datastore: %s", err.Error())
+	}
+
+	_, err = datastore.DB.Exec("TRUNCATE drive, folder, file, additional_parent")
+	if err != nil {
+		t.Fatalf("Could not truncate tables: %s", err.Error())
+	}
+
+	t.Cleanup(func() { datastore.DB.Close() })
+	return datastore
+}
+
+func TestValuesQuery(t *testing.T) {
+	query := valuesQuery("INSERT INTO t (a, b)", " ON CONFLICT DO NOTHING", 2, 3)
+	expected := "INSERT INTO t (a, b) VALUES ($1, $2), ($3, $4), ($5, $6) ON CONFLICT DO NOTHING"
+
+	if query != expected {
+		t.Errorf("Query does not match: %s", query)
+	}
+}
+
+func TestUniqueFiles(t *testing.T) {
+	files := []ds.File{
+		{ID: "Z", Name: "old name"},
+		{ID: "Y", Name: "file Y"},
+		{ID: "Z", Name: "new name"},
+	}
+
+	expected := []ds.File{
+		{ID: "Z", Name: "new name"},
+		{ID: "Y", Name: "file Y"},
+	}
+
+	if unique := uniqueFiles(files); !reflect.DeepEqual(unique, expected) {
+		t.Log(unique)
+		t.Error("Files do not match")
+	}
+}
+
+func TestWriteRows(t *testing.T) {
+	folders := []ds.Folder{
+		{ID: "A", Name: "old name", Parent: "drive", AdditionalParents: []string{"B", "C"}},
+		{ID: "B", Name: "Folder B", Parent: "drive"},
+		{ID: "A", Name: "Folder A", Parent: "drive", AdditionalParents: []string{"C"}},
+	}
+
+	files := []ds.File{
+		{ID: "Z", Name: "File Z", Parent: "A", AdditionalParents: []string{"B"}},
+		{ID: "Z", Name: "File Z", Parent: "A", AdditionalParents: []string{"B"}},
+	}
+
+	rows := newWriteRows("drive", folders, files)
+
+	expectedFolders := [][]interface{}{
+		folderRow("drive", folders[2]),
+		folderRow("drive", folders[1]),
+	}
+
+	if !reflect.DeepEqual(rows.folders, expectedFolders) {
+		t.Errorf("Folder rows do not match: %v", rows.folders)
+	}
+
+	if !reflect.DeepEqual(rows.files, [][]interface{}{fileRow("drive", files[1])}) {
+		t.Errorf("File rows do not match: %v", rows.files)
+	}
+
+	if !reflect.DeepEqual(rows.ids, []string{"A", "B", "Z"}) {
+		t.Errorf("IDs do not match: %v", rows.ids)
+	}
+
+	// Every additional parent is only inserted once, for the last occurrence of the item.
+	expectedParents := [][]interface{}{
+		{"A", "drive", "C", 0},
+		{"Z", "drive", "B", 0},
+	}
+
+	if !reflect.DeepEqual(rows.parents, expectedParents) {
+		t.Errorf("Additional parent rows do not match: %v", rows.parents)
+	}
+}
+
+func TestFolderRow(t *testing.T) {
+	root := folderRow("drive", ds.Folder{ID: "drive", Name: "Shared Drive"})
+	if root[3] != nil {
+		t.Errorf("Expected the parent of the root folder to be NULL, got: %v", root[3])
+	}
+
+	extra, err := root[5].(extraJSON).Value()
+	if err != nil || extra != "" {
+		t.Errorf("Expected empty extra properties, got: %v, %v", extra, err)
+	}
+}
+
+func TestConstraintErr(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		is   error
+	}{
+		{"foreign key violation", &pq.Error{Code: "23503"}, ds.ErrDataAnomaly},
+		{"unique violation", &pq.Error{Code: "23505"}, ds.ErrDataAnomaly},
+		{"cardinality violation", &pq.Error{Code: "21000"}, ds.ErrDatabase},
+		{"undefined table", &pq.Error{Code: "42P01"}, ds.ErrDatabase},
+		{"wrapped violation", fmt.Errorf("commit: %w", &pq.Error{Code: "23503"}), ds.ErrDataAnomaly},
+		{"other error", errors.New("connection reset"), ds.ErrDatabase},
+	}
+
+	for _, tc := range tests {
+		if err := constraintErr(tc.err, "test"); !errors.Is(err, tc.is) {
+			t.Errorf("%s: expected %v, got: %v", tc.name, tc.is, err)
+		}
+	}
+}
+
+func TestFullSync(t *testing.T) {
+	store := setupTest(t)
+
+	drive := ds.Drive{ID: "drive", Name: "Shared Drive", PageToken: "1"}
+	created := time.Date(2020, 3, 2, 12, 30, 15, 123000000, time.UTC)
+
+	// Folder B is listed before its parent to test the deferred constraints.
+	folders := []ds.Folder{
+		{ID: "B", Name: "Folder B", Parent: "A"},
+		{ID: "A", Name: "Folder A", Parent: "drive"},
+	}
+
+	files := []ds.File{
+		{ID: "Z", Name: "File Z", Parent: "B", AdditionalParents: []string{"A"}, MD5: "ZZZ", Size: 10,
+			MimeType: "image/png", CreatedTime: created, ModifiedTime: created, Version: 2},
+		{ID: "Y", Name: "To Folder A", Parent: "drive", MimeType: "application/vnd.google-apps.shortcut",
+			CreatedTime: created, ModifiedTime: created,
+			Shortcut: &ds.Shortcut{TargetID: "A", TargetMimeType: "application/vnd.google-apps.folder"}},
+	}
+
+	err := store.FullSync(drive, folders, files)
+	if err != nil {
+		t.Fatalf("Unexpected error at full sync: %s", err.Error())
+	}
+
+	pageToken, err := store.PageToken(drive.ID)
+	if err != nil || pageToken != drive.PageToken {
+		t.Errorf("Unexpected pageToken %q: %v", pageToken, err)
+	}
+
+	// No changes should be reported for the items which were just synced.
+	hook, diff := store.NewDifferencesHook()
+	err = hook(drive, files, folders, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error when running hook: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(diff, &Difference{}) {
+		t.Log(diff)
+		t.Error("Difference should be empty")
+	}
+
+	err = store.FullSync(ds.Drive{ID: "other", PageToken: "1"}, nil, []ds.File{
+		{ID: "X", Name: "File X", Parent: "unknown"},
+	})
+
+	if !errors.Is(err, ds.ErrDataAnomaly) {
+		t.Errorf("Expected a data anomaly, got: %v", err)
+	}
+}
+
+func TestDuplicateIDs(t *testing.T) {
+	store := setupTest(t)
+
+	drive := ds.Drive{ID: "drive", Name: "Shared Drive", PageToken: "1"}
+	err := store.FullSync(drive, []ds.Folder{{ID: "A", Name: "Folder A", Parent: "drive"}}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error at full sync: %s", err.Error())
+	}
+
+	// A change set can contain the same item twice, each with additional parents.
+	files := []ds.File{
+		{ID: "Z", Name: "File Z", Parent: "drive", AdditionalParents: []string{"A"}},
+		{ID: "Z", Name: "File Z", Parent: "drive", AdditionalParents: []string{"A"}},
+	}
+
+	err = store.PartialSync(ds.Drive{ID: "drive", PageToken: "2"}, nil, files, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error at partial sync: %v", err)
+	}
+}
+
+func TestPartialSync(t *testing.T) {
+	store := setupTest(t)
+
+	drive := ds.Drive{ID: "drive", Name: "Shared Drive", PageToken: "1"}
+
+	err := store.FullSync(drive,
+		[]ds.Folder{
+			{ID: "A", Name: "Folder A", Parent: "drive"},
+			{ID: "B", Name: "Folder B", Parent: "A"},
+		},
+		[]ds.File{
+			{ID: "Z", Name: "File Z", Parent: "A", Size: 10},
+			{ID: "Y", Name: "File Y", Parent: "B", Size: 20},
+		},
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error at full sync: %s", err.Error())
+	}
+
+	changes := ds.Drive{ID: "drive", PageToken: "2"}
+	folders := []ds.Folder{{ID: "C", Name: "Folder C", Parent: "drive"}}
+	files := []ds.File{
+		{ID: "Z", Name: "File Z", Parent: "C", Size: 15},
+		{ID: "X", Name: "File X", Parent: "C", Size: 30},
+	}
+
+	hook, diff := store.NewDifferencesHook()
+	err = hook(changes, files, folders, []string{"B", "Y"})
+	if err != nil {
+		t.Fatalf("Unexpected error when running hook: %s", err.Error())
+	}
+
+	expected := &Difference{
+		AddedFolders: []ds.Folder{{ID: "C", Name: "Folder C", Parent: "drive"}},
+		AddedFiles:   []ds.File{{ID: "X", Name: "File X", Parent: "C", Size: 30}},
+		ChangedFiles: []FileDifference{{
+			Old: ds.File{ID: "Z", Name: "File Z", Parent: "A", Size: 10},
+			New: ds.File{ID: "Z", Name: "File Z", Parent: "C", Size: 15},
+		}},
+		RemovedFolders: []ds.Folder{{ID: "B", Name: "Folder B", Parent: "A"}},
+		RemovedFiles:   []ds.File{{ID: "Y", Name: "File Y", Parent: "B", Size: 20}},
+	}
+
+	if !reflect.DeepEqual(diff, expected) {
+		t.Log(diff)
+		t.Log(expected)
+		t.Error("Difference does not match the expected outcome")
+	}
+
+	err = store.PartialSync(changes, folders, files, []string{"B", "Y"})
+	if err != nil {
+		t.Fatalf("Unexpected error at partial sync: %s", err.Error())
+	}
+
+	pageToken, err := store.PageToken(drive.ID)
+	if err != nil || pageToken != changes.PageToken {
+		t.Errorf("Unexpected pageToken %q: %v", pageToken, err)
+	}
+
+	// Removing folder C while it still contains files is a data anomaly.
+	err = store.PartialSync(ds.Drive{ID: "drive", PageToken: "3"}, nil, nil, []string{"C"})
+	if !errors.Is(err, ds.ErrDataAnomaly) {
+		t.Errorf("Expected a data anomaly, got: %v", err)
+	}
+
+	pageToken, _ = store.PageToken(drive.ID)
+	if pageToken != changes.PageToken {
+		t.Errorf("pageToken should not be updated after a data anomaly, got: %s", pageToken)
+	}
+}
@@ -0,0 +1,94 @@
+package sqlite
+
+import (
+	"testing"
+
+	ds "github.com/m-rots/bernard/datastore"
+	"github.com/m-rots/bernard/datastore/datastoretest"
+)
+
+func TestConformance(t *testing.T) {
+	datastoretest.Run(t, func(t *testing.T) ds.Datastore {
+		return setupTest(t)
+	}, listItems)
+}
+
+// listItems returns all folders and files of the Drive sorted on ID.
+//
+// Every connection to an in-memory database opens another database.
+// Therefore, all rows are read before the next query is made.
+func listItems(t *testing.T, store ds.Datastore, driveID string) (folders []ds.Folder, files []ds.File) {
+	t.Helper()
+
+	db := store.(*Datastore).DB
+
+	getParents, err := db.Prepare(sqlGetAdditionalParents)
+	if err != nil {
+		t.Fatalf("Could not prepare statement: %s", err.Error())
+	}
+
+	defer getParents.Close()
+
+	folderRows, err := db.Query("SELECT id, name, IFNULL(parent, ''), trashed, extra FROM folder WHERE drive=? ORDER BY id", driveID)
+	if err != nil {
+		t.Fatalf("Could not query folder rows: %s", err.Error())
+	}
+
+	defer folderRows.Close()
+	for folderRows.Next() {
+		f := ds.Folder{}
+
+		err = folderRows.Scan(&f.ID, &f.Name, &f.Parent, &f.Trashed, (*extraJSON)(&f.Extra))
+		if err != nil {
+			t.Fatalf("Error when scanning folder rows: %s", err.Error())
+		}
+
+		folders = append(folders, f)
+	}
+
+	folderRows.Close()
+	for i := range folders {
+		folders[i].AdditionalParents, err = scanParents(getParents, driveID, folders[i].ID)
+		if err != nil {
+			t.Fatalf("Error when scanning additional parents: %s", err.Error())
+		}
+	}
+
+	fileRows, err := db.Query("SELECT id FROM file WHERE drive=? ORDER BY id", driveID)
+	if err != nil {
+		t.Fatalf("Could not query file rows: %s", err.Error())
+	}
+
+	defer fileRows.Close()
+	for fileRows.Next() {
+		f := ds.File{}
+
+		if err = fileRows.Scan(&f.ID); err != nil {
+			t.Fatalf("Error when scanning file rows: %s", err.Error())
+		}
+
+		files = append(files, f)
+	}
+
+	fileRows.Close()
+	for i := range files {
+		err = scanFile(db.QueryRow(sqlGetFileByID, files[i].ID, driveID), getParents, driveID, &files[i])
+		if err != nil {
+			t.Fatalf("Error when scanning file: %s", err.Error())
+		}
+	}
+
+	return folders, files
+}
+
+func TestReaderConformance(t *testing.T) {
+	datastoretest.RunReader(t, func(t *testing.T) ds.Reader {
+		return setupTest(t)
+	})
+}
+
+func TestShortcutConformance(t *testing.T) {
+	datastoretest.RunShortcuts(t, func(t *testing.T) ds.ShortcutResolver {
+		return setupTest(t)
+	})
+}
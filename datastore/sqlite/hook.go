@@ -1,9 +1,11 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/m-rots/bernard"
 	ds "github.com/m-rots/bernard/datastore"
@@ -11,6 +13,11 @@ import (
 
 // The Difference contains all added, changes and removed files and folders
 // between two states.
+//
+// Every item carries its full path in the state it belongs to. The old state
+// is the state of the datastore before the sync, while the paths of the new state
+// are resolved by applying the changes to the old state. Added items and the new state
+// of changed items have an empty path when their parent is unknown in both states.
 type Difference struct {
 	AddedFiles   []ds.File
 	ChangedFiles []FileDifference
@@ -19,27 +26,140 @@ type Difference struct {
 	AddedFolders   []ds.Folder
 	ChangedFolders []FolderDifference
 	RemovedFolders []ds.Folder
+
+	// TrashedFiles and TrashedFolders hold the last-known state of the descendants
+	// of trashed folders. These are only filled with the HookExpandDescendants option.
+	TrashedFiles   []ds.File
+	TrashedFolders []ds.Folder
+}
+
+// A ChangeKind is a set of the kinds of changes between the old and new state of an item.
+// Changes which do not fit any of the kinds, such as changed additional parents,
+// result in an empty set.
+type ChangeKind uint
+
+const (
+	// Renamed indicates a change of the name.
+	Renamed ChangeKind = 1 << iota
+
+	// Moved indicates a change of the primary parent.
+	Moved
+
+	// Trashed indicates the item has been moved to the trash.
+	Trashed
+
+	// Restored indicates the item has been restored from the trash.
+	Restored
+
+	// ContentChanged indicates a change of the MD5 checksum or size of a file.
+	ContentChanged
+
+	// AncestorChanged indicates a change of the path caused by a renamed or moved ancestor,
+	// while the primary parent of the item itself stayed the same.
+	AncestorChanged
+)
+
+var changeKindNames = []string{"renamed", "moved", "trashed", "restored", "content changed", "ancestor changed"}
+
+// Has reports whether all kinds of the provided set are part of the set.
+func (kind ChangeKind) Has(other ChangeKind) bool {
+	return kind&other == other
+}
+
+// String returns the names of the kinds in the set, separated by a pipe.
+func (kind ChangeKind) String() string {
+	var names []string
+
+	for i, name := range changeKindNames {
+		if kind.Has(1 << i) {
+			names = append(names, name)
+		}
+	}
+
+	return strings.Join(names, "|")
 }
 
+// A FolderDifference holds the old and new state of a changed folder,
+// together with the kinds of changes between the two.
 type FolderDifference struct {
-	Old ds.Folder
-	New ds.Folder
+	Old  ds.Folder
+	New  ds.Folder
+	Kind ChangeKind
 }
 
+// A FileDifference holds the old and new state of a changed file,
+// together with the kinds of changes between the two.
 type FileDifference struct {
-	Old ds.File
-	New ds.File
+	Old  ds.File
+	New  ds.File
+	Kind ChangeKind
+}
+
+// A HookOption can change the behaviour of the DifferencesHook.
+type HookOption func(*differencesHook)
+
+type differencesHook struct {
+	expandDescendants bool
+	expandPaths       bool
+}
+
+// HookExpandDescendants expands trashed folders to all their descendants.
+//
+// Google Drive only reports the folder itself when a folder is trashed.
+// With this option, the last-known state of every file and folder within a newly trashed folder
+// is added to the TrashedFiles and TrashedFolders, except for those which were trashed already.
+//
+// Removed folders are not expanded, as the datastore only accepts the removal of a folder
+// once all its descendants have been removed or moved by the same changes.
+// These descendants are therefore part of the changes and reported on their own.
+//
+// Items which are part of the changes themselves are not reported again.
+func HookExpandDescendants() HookOption {
+	return func(h *differencesHook) {
+		h.expandDescendants = true
+	}
+}
+
+// HookExpandPaths reports the implied path changes of the descendants of renamed and moved folders.
+//
+// Every descendant which is not part of the changes itself is added to the ChangedFiles
+// and ChangedFolders with the AncestorChanged kind. The Old and New state only differ in their Path.
+func HookExpandPaths() HookOption {
+	return func(h *differencesHook) {
+		h.expandPaths = true
+	}
 }
 
 // NewDifferencesHook creates a Hook which checks which files and folders
 // have been added, changed or removed.
 //
 // Like all hooks, the corresponding output struct is only updated
-// when the hook is executed.
-func (store *Datastore) NewDifferencesHook() (bernard.Hook, *Difference) {
+// when the hook is executed. Every execution replaces the Difference of the
+// previous one, so a hook used by Watch or a PushReceiver only holds the
+// differences of the latest partial sync.
+//
+// The hook and its Difference are not safe for concurrent use.
+// When multiple Shared Drives are watched, the hooks run concurrently,
+// so every Shared Drive needs its own Watch and hook.
+func (store *Datastore) NewDifferencesHook(opts ...HookOption) (bernard.Hook, *Difference) {
 	var diff Difference
 
+	config := differencesHook{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
 	hook := func(drive ds.Drive, files []ds.File, folders []ds.Folder, removed []string) error {
+		diff = Difference{}
+
+		// prepare the `sqlGetAdditionalParents` statement for better performance
+		getParents, err := store.DB.Prepare(sqlGetAdditionalParents)
+		if err != nil {
+			return fmt.Errorf("%v: %w", sqlGetAdditionalParents, ErrInvalidStatement)
+		}
+
+		defer getParents.Close()
+
 		// prepare the `sqlGetFolderByID` statement for better performance
 		getFolder, err := store.DB.Prepare(sqlGetFolderByID)
 		if err != nil {
@@ -48,10 +168,25 @@ func (store *Datastore) NewDifferencesHook() (bernard.Hook, *Difference) {
 
 		defer getFolder.Close()
 
+		paths, err := newPathResolver(store.DB, drive.ID, folders)
+		if err != nil {
+			return err
+		}
+
+		defer paths.Close()
+
+		// the IDs of the folders which are trashed, renamed or moved by the changes
+		var trashedIDs, movedIDs []string
+
 		for _, folder := range folders {
+			folder.Path, err = paths.folderPath(folder.ID)
+			if err != nil {
+				return fmt.Errorf("folder path err in hook: %w", ds.ErrDatabase)
+			}
+
 			f := ds.Folder{ID: folder.ID}
 			row := getFolder.QueryRow(folder.ID, drive.ID)
-			err := row.Scan(&f.Name, &f.Parent, &f.Trashed)
+			err := scanFolder(row, getParents, drive.ID, &f)
 			if err != nil {
 				// If no row is returned, the folder does not yet exist in the old state.
 				// Therefore, this folder must have been added.
@@ -65,8 +200,17 @@ func (store *Datastore) NewDifferencesHook() (bernard.Hook, *Difference) {
 
 			// If any of the fields do not align between the old and new state,
 			// then this folder must have been changed.
-			if f.Name != folder.Name || f.Parent != folder.Parent || f.Trashed != folder.Trashed {
-				diff.ChangedFolders = append(diff.ChangedFolders, FolderDifference{Old: f, New: folder})
+			if ds.FolderChanged(f, folder) {
+				kind := folderChangeKind(f, folder)
+				diff.ChangedFolders = append(diff.ChangedFolders, FolderDifference{Old: f, New: folder, Kind: kind})
+
+				if kind.Has(Trashed) {
+					trashedIDs = append(trashedIDs, folder.ID)
+				}
+
+				if kind&(Renamed|Moved) != 0 {
+					movedIDs = append(movedIDs, folder.ID)
+				}
 			}
 		}
 
@@ -79,9 +223,14 @@ func (store *Datastore) NewDifferencesHook() (bernard.Hook, *Difference) {
 		defer getFile.Close()
 
 		for _, file := range files {
+			file.Path, err = paths.path(file.Parent, file.Name)
+			if err != nil {
+				return fmt.Errorf("file path err in hook: %w", ds.ErrDatabase)
+			}
+
 			f := ds.File{ID: file.ID}
 			row := getFile.QueryRow(file.ID, drive.ID)
-			err := row.Scan(&f.Name, &f.Parent, &f.Trashed, &f.Size, &f.MD5)
+			err := scanFile(row, getParents, drive.ID, &f)
 			if err != nil {
 				// If no row is returned, the file does not yet exist in the old state.
 				// Therefore, this file must have been added.
@@ -95,8 +244,8 @@ func (store *Datastore) NewDifferencesHook() (bernard.Hook, *Difference) {
 
 			// If any of the fields do not align between the old and new state,
 			// then this file must have been changed.
-			if f.Name != file.Name || f.Parent != file.Parent || f.Trashed != file.Trashed || f.Size != file.Size || f.MD5 != file.MD5 {
-				diff.ChangedFiles = append(diff.ChangedFiles, FileDifference{Old: f, New: file})
+			if ds.FileChanged(f, file) {
+				diff.ChangedFiles = append(diff.ChangedFiles, FileDifference{Old: f, New: file, Kind: fileChangeKind(f, file)})
 			}
 		}
 
@@ -104,7 +253,7 @@ func (store *Datastore) NewDifferencesHook() (bernard.Hook, *Difference) {
 			// check whether it could be a file first
 			file := ds.File{ID: id}
 			fileRow := getFile.QueryRow(id, drive.ID)
-			err := fileRow.Scan(&file.Name, &file.Parent, &file.Trashed, &file.Size, &file.MD5)
+			err := scanFile(fileRow, getParents, drive.ID, &file)
 
 			// no error -> thus a file
 			if err == nil {
@@ -119,7 +268,7 @@ func (store *Datastore) NewDifferencesHook() (bernard.Hook, *Difference) {
 			// file did not return, try again for folder
 			folder := ds.Folder{ID: id}
 			folderRow := getFolder.QueryRow(id, drive.ID)
-			err = folderRow.Scan(&folder.Name, &folder.Parent, &folder.Trashed)
+			err = scanFolder(folderRow, getParents, drive.ID, &folder)
 
 			// no error -> thus a folder
 			if err == nil {
@@ -136,16 +285,358 @@ func (store *Datastore) NewDifferencesHook() (bernard.Hook, *Difference) {
 			return fmt.Errorf("removed folder scan err in hook: %w", ds.ErrDatabase)
 		}
 
+		if config.expandDescendants {
+			reported := reportedIDs(folders, files, removed)
+
+			err = store.expandDescendants(&diff, getParents, getFolder, getFile, drive.ID, trashedIDs, reported)
+			if err != nil {
+				return err
+			}
+		}
+
+		if config.expandPaths {
+			reported := reportedIDs(folders, files, removed)
+
+			err = store.expandPaths(&diff, paths, getParents, getFolder, getFile, drive.ID, movedIDs, reported)
+			if err != nil {
+				return err
+			}
+		}
+
 		return nil
 	}
 
 	return hook, &diff
 }
 
+// reportedIDs returns the IDs of the items which are part of the changes,
+// as these are already reported by the hook.
+func reportedIDs(folders []ds.Folder, files []ds.File, removed []string) map[string]bool {
+	reported := make(map[string]bool, len(folders)+len(files)+len(removed))
+	for _, f := range folders {
+		reported[f.ID] = true
+	}
+
+	for _, f := range files {
+		reported[f.ID] = true
+	}
+
+	for _, id := range removed {
+		reported[id] = true
+	}
+
+	return reported
+}
+
+// expandDescendants adds the descendants of the trashed folders to the Difference.
+func (store *Datastore) expandDescendants(diff *Difference, getParents, getFolder, getFile *sql.Stmt, driveID string, trashedIDs []string, reported map[string]bool) error {
+	for _, id := range trashedIDs {
+		descendantFolders, descendantFiles, err := store.descendants(getParents, getFolder, getFile, driveID, id, false, reported)
+		if err != nil {
+			return fmt.Errorf("trashed descendants scan err in hook: %w", ds.ErrDatabase)
+		}
+
+		diff.TrashedFolders = append(diff.TrashedFolders, descendantFolders...)
+		diff.TrashedFiles = append(diff.TrashedFiles, descendantFiles...)
+	}
+
+	return nil
+}
+
+// expandPaths adds the descendants of the renamed and moved folders to the Difference,
+// with the path of the new state.
+func (store *Datastore) expandPaths(diff *Difference, paths *pathResolver, getParents, getFolder, getFile *sql.Stmt, driveID string, movedIDs []string, reported map[string]bool) error {
+	for _, id := range movedIDs {
+		descendantFolders, descendantFiles, err := store.descendants(getParents, getFolder, getFile, driveID, id, true, reported)
+		if err != nil {
+			return fmt.Errorf("moved descendants scan err in hook: %w", ds.ErrDatabase)
+		}
+
+		for _, old := range descendantFolders {
+			folder := old
+			if folder.Path, err = paths.folderPath(folder.ID); err != nil {
+				return fmt.Errorf("folder path err in hook: %w", ds.ErrDatabase)
+			}
+
+			diff.ChangedFolders = append(diff.ChangedFolders, FolderDifference{Old: old, New: folder, Kind: AncestorChanged})
+		}
+
+		for _, old := range descendantFiles {
+			file := old
+			if file.Path, err = paths.path(file.Parent, file.Name); err != nil {
+				return fmt.Errorf("file path err in hook: %w", ds.ErrDatabase)
+			}
+
+			diff.ChangedFiles = append(diff.ChangedFiles, FileDifference{Old: old, New: file, Kind: AncestorChanged})
+		}
+	}
+
+	return nil
+}
+
+// descendants returns the stored folders and files within the folder and all of its subfolders,
+// following the primary parents. Items which have already been reported are skipped,
+// after which they are marked as reported.
+//
+// Trashed items and their descendants are only included when includeTrashed is set.
+func (store *Datastore) descendants(getParents, getFolder, getFile *sql.Stmt, driveID, folderID string, includeTrashed bool, reported map[string]bool) (folders []ds.Folder, files []ds.File, err error) {
+	ctx := context.Background()
+
+	folderIDs, err := store.queryIDs(ctx, sqlGetDescendantFolders, driveID, folderID, includeTrashed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, id := range folderIDs {
+		if reported[id] {
+			continue
+		}
+
+		f := ds.Folder{ID: id}
+		if err = scanFolder(getFolder.QueryRow(id, driveID), getParents, driveID, &f); err != nil {
+			return nil, nil, err
+		}
+
+		reported[id] = true
+		folders = append(folders, f)
+	}
+
+	fileIDs, err := store.queryIDs(ctx, sqlGetDescendantFiles, driveID, folderID, includeTrashed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, id := range fileIDs {
+		if reported[id] {
+			continue
+		}
+
+		f := ds.File{ID: id}
+		if err = scanFile(getFile.QueryRow(id, driveID), getParents, driveID, &f); err != nil {
+			return nil, nil, err
+		}
+
+		reported[id] = true
+		files = append(files, f)
+	}
+
+	return folders, files, nil
+}
+
+// A pathResolver resolves the paths of the new state by applying the changed folders
+// to the folders of the datastore.
+type pathResolver struct {
+	getFolder *sql.Stmt
+	driveID   string
+	changed   map[string]ds.Folder
+	paths     map[string]string
+}
+
+func newPathResolver(db *sql.DB, driveID string, changed []ds.Folder) (*pathResolver, error) {
+	getFolder, err := db.Prepare(sqlGetFolderName)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", sqlGetFolderName, ErrInvalidStatement)
+	}
+
+	r := &pathResolver{
+		getFolder: getFolder,
+		driveID:   driveID,
+		changed:   make(map[string]ds.Folder, len(changed)),
+		paths:     make(map[string]string),
+	}
+
+	for _, f := range changed {
+		r.changed[f.ID] = f
+	}
+
+	return r, nil
+}
+
+// Close closes the prepared statement of the resolver.
+func (r *pathResolver) Close() error {
+	return r.getFolder.Close()
+}
+
+// path returns the path of an item with the provided parent and name in the new state.
+func (r *pathResolver) path(parent string, name string) (string, error) {
+	parentPath, err := r.folderPath(parent)
+
+	switch {
+	case err != nil || parentPath == "":
+		return "", err
+	case parentPath == "/":
+		return "/" + name, nil
+	default:
+		return parentPath + "/" + name, nil
+	}
+}
+
+// folderPath returns the path of the folder in the new state,
+// or an empty string when the folder or one of its ancestors is unknown.
+func (r *pathResolver) folderPath(id string) (string, error) {
+	if path, ok := r.paths[id]; ok {
+		return path, nil
+	}
+
+	// Mark the folder as unknown while its ancestors are resolved,
+	// so a cycle of parents cannot recurse endlessly.
+	r.paths[id] = ""
+
+	f, ok := r.changed[id]
+	if !ok {
+		err := r.getFolder.QueryRow(id, r.driveID).Scan(&f.Name, &f.Parent)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	path := "/"
+	if f.Parent != "" {
+		var err error
+		if path, err = r.path(f.Parent, f.Name); err != nil {
+			return "", err
+		}
+	}
+
+	r.paths[id] = path
+	return path, nil
+}
+
+// scanFile scans a row of sqlGetFileByID and the additional parents into the provided file.
+func scanFile(row *sql.Row, getParents *sql.Stmt, driveID string, f *ds.File) (err error) {
+	var targetID, targetMimeType sql.NullString
+
+	err = row.Scan(&f.Name, &f.Parent, &f.Path, &f.Trashed, &f.Size, &f.MD5, &f.SHA1, &f.SHA256,
+		&f.MimeType, &f.CreatedTime, &f.ModifiedTime, &f.Version,
+		&f.FileExtension, &f.OriginalFilename, &f.HeadRevisionID, (*extraJSON)(&f.Extra),
+		&targetID, &targetMimeType)
+	if err != nil {
+		return err
+	}
+
+	if targetID.Valid {
+		f.Shortcut = &ds.Shortcut{TargetID: targetID.String, TargetMimeType: targetMimeType.String}
+	}
+
+	f.AdditionalParents, err = scanParents(getParents, driveID, f.ID)
+	return err
+}
+
+// scanFolder scans a row of sqlGetFolderByID and the additional parents into the provided folder.
+func scanFolder(row *sql.Row, getParents *sql.Stmt, driveID string, f *ds.Folder) (err error) {
+	err = row.Scan(&f.Name, &f.Parent, &f.Path, &f.Trashed, (*extraJSON)(&f.Extra))
+	if err != nil {
+		return err
+	}
+
+	f.AdditionalParents, err = scanParents(getParents, driveID, f.ID)
+	return err
+}
+
+// scanParents returns the additional parents of an item, or nil when it does not have any.
+func scanParents(getParents *sql.Stmt, driveID, id string) (parents []string, err error) {
+	rows, err := getParents.Query(id, driveID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var parent string
+		if err = rows.Scan(&parent); err != nil {
+			return nil, err
+		}
+
+		parents = append(parents, parent)
+	}
+
+	return parents, rows.Err()
+}
+
+// folderChangeKind returns the kinds of changes between the old and new state of a folder.
+func folderChangeKind(old, new ds.Folder) (kind ChangeKind) {
+	if old.Name != new.Name {
+		kind |= Renamed
+	}
+
+	if old.Parent != new.Parent {
+		kind |= Moved
+	}
+
+	if !old.Trashed && new.Trashed {
+		kind |= Trashed
+	}
+
+	if old.Trashed && !new.Trashed {
+		kind |= Restored
+	}
+
+	// The path of the parent only changes without a move when an ancestor changed.
+	if old.Parent == new.Parent && old.Path != "" && new.Path != "" && parentPath(old.Path) != parentPath(new.Path) {
+		kind |= AncestorChanged
+	}
+
+	return kind
+}
+
+// fileChangeKind returns the kinds of changes between the old and new state of a file.
+func fileChangeKind(old, new ds.File) ChangeKind {
+	kind := folderChangeKind(
+		ds.Folder{Name: old.Name, Parent: old.Parent, Trashed: old.Trashed, Path: old.Path},
+		ds.Folder{Name: new.Name, Parent: new.Parent, Trashed: new.Trashed, Path: new.Path},
+	)
+
+	if old.MD5 != new.MD5 || old.Size != new.Size {
+		kind |= ContentChanged
+	}
+
+	return kind
+}
+
+// parentPath returns the path without its last element.
+func parentPath(path string) string {
+	return path[:strings.LastIndexByte(path, '/')+1]
+}
+
 const sqlGetFileByID = `
-SELECT name, parent, trashed, size, md5 FROM file WHERE id=? AND drive=?
+SELECT name, parent, IFNULL(path, ''), trashed, size, md5, sha1, sha256, mimeType, createdTime, modifiedTime,
+	version, fileExtension, originalFilename, headRevisionId, extra,
+	shortcutTargetId, shortcutTargetMimeType
+FROM file WHERE id=? AND drive=?
 `
 
 const sqlGetFolderByID = `
-SELECT name, parent, trashed FROM folder WHERE id=? AND drive=?
+SELECT name, IFNULL(parent, ''), IFNULL(path, ''), trashed, extra FROM folder WHERE id=? AND drive=?
+`
+
+const sqlGetFolderName = `
+SELECT name, IFNULL(parent, '') FROM folder WHERE id=? AND drive=?
+`
+
+const sqlGetDescendantFolders = `
+WITH RECURSIVE tree(id) AS (
+	SELECT id FROM folder WHERE drive=?1 AND parent=?2 AND (?3 OR NOT trashed)
+	UNION
+	SELECT folder.id FROM folder JOIN tree ON folder.parent=tree.id
+	WHERE folder.drive=?1 AND (?3 OR NOT folder.trashed)
+)
+SELECT id FROM tree
+`
+
+const sqlGetDescendantFiles = `
+WITH RECURSIVE tree(id) AS (
+	SELECT ?2
+	UNION
+	SELECT folder.id FROM folder JOIN tree ON folder.parent=tree.id
+	WHERE folder.drive=?1 AND (?3 OR NOT folder.trashed)
+)
+SELECT id FROM file WHERE drive=?1 AND parent IN (SELECT id FROM tree) AND (?3 OR NOT trashed) ORDER BY id
+`
+
+const sqlGetAdditionalParents = `
+SELECT parent FROM additional_parent WHERE id=? AND drive=? ORDER BY position
 `
@@ -1,9 +1,11 @@
 package sqlite
 
 import (
+	"encoding/json"
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	ds "github.com/m-rots/bernard/datastore"
 )
@@ -36,6 +38,8 @@ func TestDifferenceHook(t *testing.T) {
 		PageToken: "123",
 	}
 
+	modified := time.Date(2020, 3, 2, 12, 30, 15, 123000000, time.UTC)
+
 	var testCases = []Test{
 		{
 			name: "added files & folders",
@@ -56,12 +60,12 @@ func TestDifferenceHook(t *testing.T) {
 			},
 			expected: &Difference{
 				AddedFolders: []ds.Folder{
-					{ID: "A", Parent: "drive", Name: "Folder A", Trashed: false},
-					{ID: "B", Parent: "A", Name: "Folder B", Trashed: true},
+					{ID: "A", Parent: "drive", Name: "Folder A", Path: "/Folder A", Trashed: false},
+					{ID: "B", Parent: "A", Name: "Folder B", Path: "/Folder A/Folder B", Trashed: true},
 				},
 				AddedFiles: []ds.File{
-					{ID: "Z", Parent: "drive", Name: "File Z", MD5: "ZZZ", Size: 10, Trashed: false},
-					{ID: "Y", Parent: "A", Name: "File Y", MD5: "YYY", Size: 100, Trashed: true},
+					{ID: "Z", Parent: "drive", Name: "File Z", Path: "/File Z", MD5: "ZZZ", Size: 10, Trashed: false},
+					{ID: "Y", Parent: "A", Name: "File Y", Path: "/Folder A/File Y", MD5: "YYY", Size: 100, Trashed: true},
 				},
 			},
 		},
@@ -87,16 +91,19 @@ func TestDifferenceHook(t *testing.T) {
 			expected: &Difference{
 				ChangedFolders: []FolderDifference{
 					{ // name
-						Old: ds.Folder{ID: "A", Name: "old name", Parent: "drive", Trashed: false},
-						New: ds.Folder{ID: "A", Name: "new name", Parent: "drive", Trashed: false},
+						Old:  ds.Folder{ID: "A", Name: "old name", Parent: "drive", Path: "/old name", Trashed: false},
+						New:  ds.Folder{ID: "A", Name: "new name", Parent: "drive", Path: "/new name", Trashed: false},
+						Kind: Renamed,
 					},
 					{ // parent
-						Old: ds.Folder{ID: "B", Name: "folder b", Parent: "drive", Trashed: false},
-						New: ds.Folder{ID: "B", Name: "folder b", Parent: "A", Trashed: false},
+						Old:  ds.Folder{ID: "B", Name: "folder b", Parent: "drive", Path: "/folder b", Trashed: false},
+						New:  ds.Folder{ID: "B", Name: "folder b", Parent: "A", Path: "/new name/folder b", Trashed: false},
+						Kind: Moved,
 					},
 					{ // trashed
-						Old: ds.Folder{ID: "C", Name: "folder c", Parent: "B", Trashed: false},
-						New: ds.Folder{ID: "C", Name: "folder c", Parent: "B", Trashed: true},
+						Old:  ds.Folder{ID: "C", Name: "folder c", Parent: "B", Path: "/folder b/folder c", Trashed: false},
+						New:  ds.Folder{ID: "C", Name: "folder c", Parent: "B", Path: "/new name/folder b/folder c", Trashed: true},
+						Kind: Trashed | AncestorChanged,
 					},
 				},
 			},
@@ -130,24 +137,122 @@ func TestDifferenceHook(t *testing.T) {
 			expected: &Difference{
 				ChangedFiles: []FileDifference{
 					{ // md5
-						Old: ds.File{ID: "Z", MD5: "old md5", Name: "file Z", Parent: "drive", Size: 10, Trashed: false},
-						New: ds.File{ID: "Z", MD5: "new md5", Name: "file Z", Parent: "drive", Size: 10, Trashed: false},
+						Old:  ds.File{ID: "Z", MD5: "old md5", Name: "file Z", Parent: "drive", Path: "/file Z", Size: 10, Trashed: false},
+						New:  ds.File{ID: "Z", MD5: "new md5", Name: "file Z", Parent: "drive", Path: "/file Z", Size: 10, Trashed: false},
+						Kind: ContentChanged,
 					},
 					{ // name
-						Old: ds.File{ID: "Y", MD5: "YYY md5", Name: "old name", Parent: "A", Size: 20, Trashed: true},
-						New: ds.File{ID: "Y", MD5: "YYY md5", Name: "new name", Parent: "A", Size: 20, Trashed: true},
+						Old:  ds.File{ID: "Y", MD5: "YYY md5", Name: "old name", Parent: "A", Path: "/folder A/old name", Size: 20, Trashed: true},
+						New:  ds.File{ID: "Y", MD5: "YYY md5", Name: "new name", Parent: "A", Path: "/folder A/new name", Size: 20, Trashed: true},
+						Kind: Renamed,
 					},
 					{ // parent
-						Old: ds.File{ID: "X", MD5: "XXX md5", Name: "file X", Parent: "A", Size: 30, Trashed: false},
-						New: ds.File{ID: "X", MD5: "XXX md5", Name: "file X", Parent: "drive", Size: 30, Trashed: false},
+						Old:  ds.File{ID: "X", MD5: "XXX md5", Name: "file X", Parent: "A", Path: "/folder A/file X", Size: 30, Trashed: false},
+						New:  ds.File{ID: "X", MD5: "XXX md5", Name: "file X", Parent: "drive", Path: "/file X", Size: 30, Trashed: false},
+						Kind: Moved,
 					},
 					{ // size
-						Old: ds.File{ID: "W", MD5: "WWW md5", Name: "file W", Parent: "A", Size: 40, Trashed: false},
-						New: ds.File{ID: "W", MD5: "WWW md5", Name: "file W", Parent: "A", Size: 80, Trashed: false},
+						Old:  ds.File{ID: "W", MD5: "WWW md5", Name: "file W", Parent: "A", Path: "/folder A/file W", Size: 40, Trashed: false},
+						New:  ds.File{ID: "W", MD5: "WWW md5", Name: "file W", Parent: "A", Path: "/folder A/file W", Size: 80, Trashed: false},
+						Kind: ContentChanged,
 					},
 					{ // trashed
-						Old: ds.File{ID: "V", MD5: "VVV md5", Name: "file V", Parent: "A", Size: 50, Trashed: true},
-						New: ds.File{ID: "V", MD5: "VVV md5", Name: "file V", Parent: "A", Size: 50, Trashed: false},
+						Old:  ds.File{ID: "V", MD5: "VVV md5", Name: "file V", Parent: "A", Path: "/folder A/file V", Size: 50, Trashed: true},
+						New:  ds.File{ID: "V", MD5: "VVV md5", Name: "file V", Parent: "A", Path: "/folder A/file V", Size: 50, Trashed: false},
+						Kind: Restored,
+					},
+				},
+			},
+		},
+		{
+			name: "changed files (metadata)",
+			err:  nil,
+			store: Store{
+				drive: drive,
+				files: []ds.File{
+					{ID: "Z", Name: "file Z", Parent: "drive", MimeType: "image/png", ModifiedTime: modified, Version: 1},
+					{ID: "Y", Name: "file Y", Parent: "drive", MimeType: "image/png", ModifiedTime: modified, Version: 1},
+				},
+			},
+			changes: Changes{
+				drive: drive,
+				files: []ds.File{
+					{ID: "Z", Name: "file Z", Parent: "drive", MimeType: "image/png", ModifiedTime: modified.Add(time.Hour), Version: 2},
+					{ID: "Y", Name: "file Y", Parent: "drive", MimeType: "image/png", ModifiedTime: modified, Version: 1},
+				},
+			},
+			expected: &Difference{
+				ChangedFiles: []FileDifference{
+					{
+						Old: ds.File{ID: "Z", Name: "file Z", Parent: "drive", Path: "/file Z", MimeType: "image/png", ModifiedTime: modified, Version: 1},
+						New: ds.File{ID: "Z", Name: "file Z", Parent: "drive", Path: "/file Z", MimeType: "image/png", ModifiedTime: modified.Add(time.Hour), Version: 2},
+					},
+				},
+			},
+		},
+		{
+			name: "changed additional parents",
+			err:  nil,
+			store: Store{
+				drive: drive,
+				folders: []ds.Folder{
+					{ID: "A", Name: "folder A", Parent: "drive"},
+					{ID: "B", Name: "folder B", Parent: "drive", AdditionalParents: []string{"A"}},
+				},
+				files: []ds.File{
+					{ID: "Z", Name: "file Z", Parent: "A", AdditionalParents: []string{"B"}},
+				},
+			},
+			changes: Changes{
+				drive: drive,
+				folders: []ds.Folder{
+					{ID: "B", Name: "folder B", Parent: "drive", AdditionalParents: []string{"A"}},
+				},
+				files: []ds.File{
+					{ID: "Z", Name: "file Z", Parent: "A"},
+				},
+			},
+			expected: &Difference{
+				ChangedFiles: []FileDifference{
+					{
+						Old: ds.File{ID: "Z", Name: "file Z", Parent: "A", Path: "/folder A/file Z", AdditionalParents: []string{"B"}},
+						New: ds.File{ID: "Z", Name: "file Z", Parent: "A", Path: "/folder A/file Z"},
+					},
+				},
+			},
+		},
+		{
+			name: "changed extra properties",
+			err:  nil,
+			store: Store{
+				drive: drive,
+				folders: []ds.Folder{
+					{ID: "A", Name: "folder A", Parent: "drive", Extra: map[string]json.RawMessage{"properties": json.RawMessage(`{"a":1}`)}},
+				},
+				files: []ds.File{
+					{ID: "Z", Name: "file Z", Parent: "A", Extra: map[string]json.RawMessage{"properties": json.RawMessage(`{"z":1}`)}},
+					{ID: "Y", Name: "file Y", Parent: "A"},
+				},
+			},
+			changes: Changes{
+				drive: drive,
+				folders: []ds.Folder{
+					{ID: "A", Name: "folder A", Parent: "drive", Extra: map[string]json.RawMessage{"properties": json.RawMessage(`{"a":1}`)}},
+				},
+				files: []ds.File{
+					{ID: "Z", Name: "file Z", Parent: "A", Extra: map[string]json.RawMessage{"properties": json.RawMessage(`{"z":2}`)}},
+					{ID: "Y", Name: "file Y", Parent: "A", Extra: map[string]json.RawMessage{"properties": json.RawMessage(`{"y":1}`)}},
+				},
+			},
+			expected: &Difference{
+				ChangedFiles: []FileDifference{
+					{
+						Old: ds.File{ID: "Z", Name: "file Z", Parent: "A", Path: "/folder A/file Z", Extra: map[string]json.RawMessage{"properties": json.RawMessage(`{"z":1}`)}},
+						New: ds.File{ID: "Z", Name: "file Z", Parent: "A", Path: "/folder A/file Z", Extra: map[string]json.RawMessage{"properties": json.RawMessage(`{"z":2}`)}},
+					},
+					{
+						Old: ds.File{ID: "Y", Name: "file Y", Parent: "A", Path: "/folder A/file Y"},
+						New: ds.File{ID: "Y", Name: "file Y", Parent: "A", Path: "/folder A/file Y", Extra: map[string]json.RawMessage{"properties": json.RawMessage(`{"y":1}`)}},
 					},
 				},
 			},
@@ -173,13 +278,62 @@ func TestDifferenceHook(t *testing.T) {
 			},
 			expected: &Difference{
 				RemovedFolders: []ds.Folder{
-					{ID: "A", Name: "folder A", Parent: "drive", Trashed: false},
-					{ID: "B", Name: "folder B", Parent: "A", Trashed: true},
+					{ID: "A", Name: "folder A", Parent: "drive", Path: "/folder A", Trashed: false},
+					{ID: "B", Name: "folder B", Parent: "A", Path: "/folder A/folder B", Trashed: true},
 				},
 				RemovedFiles: []ds.File{
-					{ID: "Z", Name: "file Z", Parent: "drive", Trashed: false, MD5: "ZZZ", Size: 10},
-					{ID: "Y", Name: "file Y", Parent: "A", Trashed: false, MD5: "YYY", Size: 100000},
-					{ID: "X", Name: "file X", Parent: "B", Trashed: true, MD5: "XXX", Size: 2525252},
+					{ID: "Z", Name: "file Z", Parent: "drive", Path: "/file Z", Trashed: false, MD5: "ZZZ", Size: 10},
+					{ID: "Y", Name: "file Y", Parent: "A", Path: "/folder A/file Y", Trashed: false, MD5: "YYY", Size: 100000},
+					{ID: "X", Name: "file X", Parent: "B", Path: "/folder A/folder B/file X", Trashed: true, MD5: "XXX", Size: 2525252},
+				},
+			},
+		},
+		{
+			name: "paths of the new state",
+			err:  nil,
+			store: Store{
+				drive: drive,
+				folders: []ds.Folder{
+					{ID: "A", Name: "Movies", Parent: "drive"},
+					{ID: "B", Name: "2020", Parent: "A"},
+				},
+				files: []ds.File{
+					{ID: "Z", Name: "foo.mkv", Parent: "B"},
+				},
+			},
+			changes: Changes{
+				drive: drive,
+				folders: []ds.Folder{
+					{ID: "A", Name: "Films", Parent: "drive"},
+					{ID: "C", Name: "2021", Parent: "A"},
+				},
+				files: []ds.File{
+					{ID: "Z", Name: "bar.mkv", Parent: "B"},
+					{ID: "Y", Name: "baz.mkv", Parent: "C"},
+					{ID: "X", Name: "unknown.mkv", Parent: "unknown"},
+				},
+			},
+			expected: &Difference{
+				AddedFolders: []ds.Folder{
+					{ID: "C", Name: "2021", Parent: "A", Path: "/Films/2021"},
+				},
+				ChangedFolders: []FolderDifference{
+					{
+						Old:  ds.Folder{ID: "A", Name: "Movies", Parent: "drive", Path: "/Movies"},
+						New:  ds.Folder{ID: "A", Name: "Films", Parent: "drive", Path: "/Films"},
+						Kind: Renamed,
+					},
+				},
+				AddedFiles: []ds.File{
+					{ID: "Y", Name: "baz.mkv", Parent: "C", Path: "/Films/2021/baz.mkv"},
+					{ID: "X", Name: "unknown.mkv", Parent: "unknown"},
+				},
+				ChangedFiles: []FileDifference{
+					{
+						Old:  ds.File{ID: "Z", Name: "foo.mkv", Parent: "B", Path: "/Movies/2020/foo.mkv"},
+						New:  ds.File{ID: "Z", Name: "bar.mkv", Parent: "B", Path: "/Films/2020/bar.mkv"},
+						Kind: Renamed | AncestorChanged,
+					},
 				},
 			},
 		},
@@ -246,3 +400,269 @@ func TestDifferenceHook(t *testing.T) {
 		})
 	}
 }
+
+func TestDifferenceHookExpandDescendants(t *testing.T) {
+	type Changes struct {
+		folders []ds.Folder
+		files   []ds.File
+		removed []string
+	}
+
+	type Test struct {
+		name     string
+		changes  Changes
+		expected *Difference
+	}
+
+	drive := ds.Drive{ID: "drive", Name: "Hooks Support", PageToken: "123"}
+
+	folders := []ds.Folder{
+		{ID: "A", Name: "folder A", Parent: "drive"},
+		{ID: "B", Name: "folder B", Parent: "A"},
+		{ID: "C", Name: "folder C", Parent: "B", Trashed: true},
+		{ID: "D", Name: "folder D", Parent: "drive", AdditionalParents: []string{"A"}},
+	}
+
+	files := []ds.File{
+		{ID: "Z", Name: "file Z", Parent: "A"},
+		{ID: "Y", Name: "file Y", Parent: "B", Trashed: true},
+		{ID: "X", Name: "file X", Parent: "C"},
+		{ID: "W", Name: "file W", Parent: "D", AdditionalParents: []string{"B"}},
+		{ID: "V", Name: "file V", Parent: "B"},
+	}
+
+	var testCases = []Test{
+		{
+			name: "removed folder is not expanded",
+			changes: Changes{
+				files:   []ds.File{{ID: "V", Name: "file V", Parent: "drive"}},
+				removed: []string{"C", "X", "B", "Y"},
+			},
+			expected: &Difference{
+				ChangedFiles: []FileDifference{
+					{
+						Old:  ds.File{ID: "V", Name: "file V", Parent: "B", Path: "/folder A/folder B/file V"},
+						New:  ds.File{ID: "V", Name: "file V", Parent: "drive", Path: "/file V"},
+						Kind: Moved,
+					},
+				},
+				RemovedFolders: []ds.Folder{
+					{ID: "C", Name: "folder C", Parent: "B", Path: "/folder A/folder B/folder C", Trashed: true},
+					{ID: "B", Name: "folder B", Parent: "A", Path: "/folder A/folder B"},
+				},
+				RemovedFiles: []ds.File{
+					{ID: "X", Name: "file X", Parent: "C", Path: "/folder A/folder B/folder C/file X"},
+					{ID: "Y", Name: "file Y", Parent: "B", Path: "/folder A/folder B/file Y", Trashed: true},
+				},
+			},
+		},
+		{
+			name: "trashed folder skips trashed descendants",
+			changes: Changes{
+				folders: []ds.Folder{{ID: "A", Name: "folder A", Parent: "drive", Trashed: true}},
+			},
+			expected: &Difference{
+				ChangedFolders: []FolderDifference{
+					{
+						Old:  ds.Folder{ID: "A", Name: "folder A", Parent: "drive", Path: "/folder A"},
+						New:  ds.Folder{ID: "A", Name: "folder A", Parent: "drive", Path: "/folder A", Trashed: true},
+						Kind: Trashed,
+					},
+				},
+				TrashedFolders: []ds.Folder{
+					{ID: "B", Name: "folder B", Parent: "A", Path: "/folder A/folder B"},
+				},
+				TrashedFiles: []ds.File{
+					{ID: "V", Name: "file V", Parent: "B", Path: "/folder A/folder B/file V"},
+					{ID: "Z", Name: "file Z", Parent: "A", Path: "/folder A/file Z"},
+				},
+			},
+		},
+		{
+			name: "folder which was trashed already",
+			changes: Changes{
+				folders: []ds.Folder{{ID: "C", Name: "renamed folder C", Parent: "B", Trashed: true}},
+			},
+			expected: &Difference{
+				ChangedFolders: []FolderDifference{
+					{
+						Old:  ds.Folder{ID: "C", Name: "folder C", Parent: "B", Path: "/folder A/folder B/folder C", Trashed: true},
+						New:  ds.Folder{ID: "C", Name: "renamed folder C", Parent: "B", Path: "/folder A/folder B/renamed folder C", Trashed: true},
+						Kind: Renamed,
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := setupTest(t)
+
+			err := store.FullSync(drive, folders, files)
+			if err != nil {
+				t.Fatalf("Unexpected error at full sync: %s", err.Error())
+			}
+
+			hook, diff := store.NewDifferencesHook(HookExpandDescendants())
+
+			// The hook runs right before the changes are written, just like in a partial sync.
+			changes := ds.Drive{ID: drive.ID, PageToken: "124"}
+			err = hook(changes, tc.changes.files, tc.changes.folders, tc.changes.removed)
+			if err != nil {
+				t.Fatalf("Unexpected error when running hook: %s", err.Error())
+			}
+
+			if !reflect.DeepEqual(diff, tc.expected) {
+				t.Log(diff)
+				t.Log(tc.expected)
+				t.Errorf("Difference does not match the expected outcome")
+			}
+
+			err = store.PartialSync(changes, tc.changes.folders, tc.changes.files, tc.changes.removed)
+			if err != nil {
+				t.Fatalf("Unexpected error at partial sync: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestChangeKind(t *testing.T) {
+	type Test struct {
+		kind     ChangeKind
+		has      ChangeKind
+		hasKind  bool
+		expected string
+	}
+
+	var testCases = []Test{
+		{kind: 0, has: Renamed, hasKind: false, expected: ""},
+		{kind: Renamed, has: Renamed, hasKind: true, expected: "renamed"},
+		{kind: Moved | Trashed, has: Trashed, hasKind: true, expected: "moved|trashed"},
+		{kind: Renamed | ContentChanged | AncestorChanged, has: Renamed | AncestorChanged, hasKind: true, expected: "renamed|content changed|ancestor changed"},
+		{kind: Restored, has: Restored | Moved, hasKind: false, expected: "restored"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			if kind := tc.kind.String(); kind != tc.expected {
+				t.Errorf("%s does not match expected value: %s", kind, tc.expected)
+			}
+
+			if has := tc.kind.Has(tc.has); has != tc.hasKind {
+				t.Errorf("Has(%s) should not be %t", tc.has, has)
+			}
+		})
+	}
+}
+
+func TestDifferenceHookExpandPaths(t *testing.T) {
+	store := setupTest(t)
+
+	drive := ds.Drive{ID: "drive", Name: "Hooks Support", PageToken: "123"}
+
+	err := store.FullSync(drive,
+		[]ds.Folder{
+			{ID: "A", Name: "Movies", Parent: "drive"},
+			{ID: "B", Name: "2020", Parent: "A"},
+			{ID: "C", Name: "Trailers", Parent: "B", Trashed: true},
+		},
+		[]ds.File{
+			{ID: "Z", Name: "foo.mkv", Parent: "B"},
+			{ID: "Y", Name: "bar.mkv", Parent: "C"},
+			{ID: "X", Name: "baz.mkv", Parent: "B"},
+		},
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error at full sync: %s", err.Error())
+	}
+
+	hook, diff := store.NewDifferencesHook(HookExpandPaths())
+
+	// A is renamed, while X is renamed as well and should only be reported once.
+	err = hook(drive,
+		[]ds.File{{ID: "X", Name: "qux.mkv", Parent: "B"}},
+		[]ds.Folder{{ID: "A", Name: "Films", Parent: "drive"}},
+		nil,
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error when running hook: %s", err.Error())
+	}
+
+	expected := &Difference{
+		ChangedFolders: []FolderDifference{
+			{
+				Old:  ds.Folder{ID: "A", Name: "Movies", Parent: "drive", Path: "/Movies"},
+				New:  ds.Folder{ID: "A", Name: "Films", Parent: "drive", Path: "/Films"},
+				Kind: Renamed,
+			},
+			{
+				Old:  ds.Folder{ID: "B", Name: "2020", Parent: "A", Path: "/Movies/2020"},
+				New:  ds.Folder{ID: "B", Name: "2020", Parent: "A", Path: "/Films/2020"},
+				Kind: AncestorChanged,
+			},
+			{
+				Old:  ds.Folder{ID: "C", Name: "Trailers", Parent: "B", Path: "/Movies/2020/Trailers", Trashed: true},
+				New:  ds.Folder{ID: "C", Name: "Trailers", Parent: "B", Path: "/Films/2020/Trailers", Trashed: true},
+				Kind: AncestorChanged,
+			},
+		},
+		ChangedFiles: []FileDifference{
+			{
+				Old:  ds.File{ID: "X", Name: "baz.mkv", Parent: "B", Path: "/Movies/2020/baz.mkv"},
+				New:  ds.File{ID: "X", Name: "qux.mkv", Parent: "B", Path: "/Films/2020/qux.mkv"},
+				Kind: Renamed | AncestorChanged,
+			},
+			{
+				Old:  ds.File{ID: "Y", Name: "bar.mkv", Parent: "C", Path: "/Movies/2020/Trailers/bar.mkv"},
+				New:  ds.File{ID: "Y", Name: "bar.mkv", Parent: "C", Path: "/Films/2020/Trailers/bar.mkv"},
+				Kind: AncestorChanged,
+			},
+			{
+				Old:  ds.File{ID: "Z", Name: "foo.mkv", Parent: "B", Path: "/Movies/2020/foo.mkv"},
+				New:  ds.File{ID: "Z", Name: "foo.mkv", Parent: "B", Path: "/Films/2020/foo.mkv"},
+				Kind: AncestorChanged,
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(diff, expected) {
+		t.Log(diff)
+		t.Log(expected)
+		t.Errorf("Difference does not match the expected outcome")
+	}
+}
+
+func TestDifferenceHookReset(t *testing.T) {
+	store := setupTest(t)
+
+	drive := ds.Drive{ID: "drive", Name: "Hooks Support", PageToken: "123"}
+
+	err := store.FullSync(drive, []ds.Folder{{ID: "A", Name: "Movies", Parent: "drive"}}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error at full sync: %s", err.Error())
+	}
+
+	hook, diff := store.NewDifferencesHook()
+	files := []ds.File{{ID: "Z", Name: "foo.mkv", Parent: "A"}}
+
+	// The second execution replaces the Difference of the first one.
+	for i := 0; i < 2; i++ {
+		err = hook(drive, files, nil, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error when running hook: %s", err.Error())
+		}
+	}
+
+	expected := &Difference{
+		AddedFiles: []ds.File{{ID: "Z", Name: "foo.mkv", Parent: "A", Path: "/Movies/foo.mkv"}},
+	}
+
+	if !reflect.DeepEqual(diff, expected) {
+		t.Log(diff)
+		t.Log(expected)
+		t.Errorf("Difference does not match the expected outcome")
+	}
+}
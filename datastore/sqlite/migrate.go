@@ -0,0 +1,175 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// schemaVersion is the version of the Schema, which is stored in the user_version of the database.
+//
+// Version 0 is the schema of the first release, which only stored the name, parent,
+// size, MD5 checksum and trashed state of the files and folders.
+const schemaVersion = 1
+
+// ErrSchemaVersion occurs when the database has been created by a newer version of Bernard.
+var ErrSchemaVersion = fmt.Errorf("unsupported schema version: %w", ds.ErrDatabase)
+
+// A column is added to an existing table when the table does not have it yet.
+//
+// Columns with a NOT NULL constraint must have a default value, as SQLite can only
+// add these to an existing table when the existing rows get the default value.
+type column struct {
+	table      string
+	name       string
+	definition string
+}
+
+// migrations holds the columns which were added to the tables of schema version 0.
+//
+// The added folders and files do not have a path yet. Their paths are built during the migration.
+var migrations = []column{
+	{"file", "sha1", `text NOT NULL DEFAULT ''`},
+	{"file", "sha256", `text NOT NULL DEFAULT ''`},
+	{"file", "mimeType", `text NOT NULL DEFAULT ''`},
+	{"file", "createdTime", `datetime NOT NULL DEFAULT '0001-01-01 00:00:00+00:00'`},
+	{"file", "modifiedTime", `datetime NOT NULL DEFAULT '0001-01-01 00:00:00+00:00'`},
+	{"file", "version", `integer NOT NULL DEFAULT 0`},
+	{"file", "fileExtension", `text NOT NULL DEFAULT ''`},
+	{"file", "originalFilename", `text NOT NULL DEFAULT ''`},
+	{"file", "headRevisionId", `text NOT NULL DEFAULT ''`},
+	{"file", "extra", `text NOT NULL DEFAULT ''`},
+	{"file", "shortcutTargetId", `text`},
+	{"file", "shortcutTargetMimeType", `text`},
+	{"file", "path", `text`},
+	{"folder", "extra", `text NOT NULL DEFAULT ''`},
+	{"folder", "path", `text`},
+}
+
+// setupSchema migrates the tables of an older schema version,
+// creates the missing tables and indexes and stores the schema version.
+func setupSchema(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("schema version: %w", ds.ErrDatabase)
+	}
+
+	if version > schemaVersion {
+		return fmt.Errorf("%d: %w", version, ErrSchemaVersion)
+	}
+
+	if version < schemaVersion {
+		if err := migrate(db); err != nil {
+			return err
+		}
+	}
+
+	if _, err := db.Exec(Schema); err != nil {
+		return fmt.Errorf("schema: %w", ds.ErrDatabase)
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version=%d", schemaVersion)); err != nil {
+		return fmt.Errorf("schema version: %w", ds.ErrDatabase)
+	}
+
+	return nil
+}
+
+// migrate adds the missing columns to the existing tables and builds the paths
+// of all folders and files within a single transaction.
+// Nothing is migrated when the tables do not exist yet.
+func migrate(db *sql.DB) error {
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin: %w", ErrTransaction)
+	}
+
+	columns := make(map[string]map[string]bool)
+	for _, c := range migrations {
+		if _, ok := columns[c.table]; !ok {
+			columns[c.table], err = tableColumns(tx, c.table)
+			if err != nil {
+				return abort(ctx, tx, err)
+			}
+		}
+
+		// The table does not exist and is created by the Schema instead.
+		if len(columns[c.table]) == 0 || columns[c.table][c.name] {
+			continue
+		}
+
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %q %s", c.table, c.name, c.definition)
+		if _, err := tx.Exec(query); err != nil {
+			return abort(ctx, tx, fmt.Errorf("%v: %w", query, ErrInvalidStatement))
+		}
+	}
+
+	if len(columns["folder"]) > 0 {
+		driveIDs, err := queryDriveIDs(tx)
+		if err != nil {
+			return abort(ctx, tx, err)
+		}
+
+		for _, driveID := range driveIDs {
+			if err := updatePaths(ctx, tx, driveID); err != nil {
+				return abort(ctx, tx, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", ErrTransaction)
+	}
+
+	return nil
+}
+
+// tableColumns returns the names of the columns of the table,
+// or an empty set when the table does not exist.
+func tableColumns(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("%v columns: %w", table, ds.ErrDatabase)
+	}
+
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, kind string
+		var defaultValue sql.NullString
+
+		if err := rows.Scan(&cid, &name, &kind, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("%v columns: %w", table, ds.ErrDatabase)
+		}
+
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
+
+// queryDriveIDs returns the IDs of the Shared Drives with a root folder.
+func queryDriveIDs(tx *sql.Tx) (driveIDs []string, err error) {
+	rows, err := tx.Query("SELECT DISTINCT drive FROM folder WHERE parent IS NULL")
+	if err != nil {
+		return nil, fmt.Errorf("drives: %w", ds.ErrDatabase)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("drives: %w", ds.ErrDatabase)
+		}
+
+		driveIDs = append(driveIDs, id)
+	}
+
+	return driveIDs, rows.Err()
+}
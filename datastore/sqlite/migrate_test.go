@@ -0,0 +1,132 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// baselineSchema is schema version 0, as created by the first release.
+const baselineSchema = `
+PRAGMA foreign_keys=ON;
+
+CREATE TABLE IF NOT EXISTS file (
+	"id" text NOT NULL,
+	"drive" text NOT NULL,
+	"name" text NOT NULL,
+	"parent" text NOT NULL,
+	"size" integer NOT NULL,
+	"md5" text NOT NULL,
+	"trashed" boolean NOT NULL,
+	PRIMARY KEY(id, drive),
+	FOREIGN KEY(parent, drive) REFERENCES folder(id, drive) DEFERRABLE INITIALLY IMMEDIATE
+);
+
+CREATE TABLE IF NOT EXISTS folder (
+	"id" text NOT NULL,
+	"drive" text NOT NULL,
+	"name" text NOT NULL,
+	"trashed" boolean NOT NULL,
+	"parent" text,
+	PRIMARY KEY(id, drive),
+	FOREIGN KEY(parent, drive) REFERENCES folder(id, drive) DEFERRABLE INITIALLY IMMEDIATE
+);
+
+CREATE TABLE IF NOT EXISTS drive (
+	"id" text NOT NULL,
+	"pageToken" text NOT NULL,
+	PRIMARY KEY(id)
+);
+
+INSERT INTO drive (id, pageToken) VALUES ('drive', '1');
+INSERT INTO folder (id, drive, name, trashed, parent) VALUES ('drive', 'drive', 'Shared Drive', false, NULL);
+INSERT INTO folder (id, drive, name, trashed, parent) VALUES ('A', 'drive', 'Folder A', false, 'drive');
+INSERT INTO file (id, drive, name, parent, size, md5, trashed) VALUES ('Z', 'drive', 'File Z', 'A', 10, 'ZZZ', false);
+`
+
+func TestMigrateBaseline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bernard-sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bernard.db")
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec(baselineSchema); err != nil {
+		t.Fatalf("Could not create the baseline database: %v", err)
+	}
+
+	db.Close()
+
+	// Opening the database again must not migrate anything twice.
+	for i := 0; i < 2; i++ {
+		store, err := New(path)
+		if err != nil {
+			t.Fatalf("Could not open the baseline database: %v", err)
+		}
+
+		var version int
+		if err := store.DB.QueryRow("PRAGMA user_version").Scan(&version); err != nil || version != schemaVersion {
+			t.Errorf("Unexpected schema version %d: %v", version, err)
+		}
+
+		file, err := store.GetFile(context.Background(), "drive", "Z")
+		if err != nil {
+			t.Fatalf("Could not get the migrated file: %v", err)
+		}
+
+		expected := &ds.File{ID: "Z", Name: "File Z", Parent: "A", Path: "/Folder A/File Z", Size: 10, MD5: "ZZZ"}
+		if !reflect.DeepEqual(file, expected) {
+			t.Errorf("Unexpected migrated file: %+v", file)
+		}
+
+		store.DB.Close()
+	}
+
+	store, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer store.DB.Close()
+
+	// The migrated database accepts the new columns.
+	err = store.PartialSync(ds.Drive{ID: "drive", PageToken: "2"},
+		[]ds.Folder{{ID: "B", Name: "Folder B", Parent: "A", AdditionalParents: []string{"drive"}}},
+		[]ds.File{{ID: "Z", Name: "File Z", Parent: "B", MimeType: "image/png", SHA1: "ZZZ1"}},
+		nil)
+
+	if err != nil {
+		t.Fatalf("Unexpected error at partial sync: %v", err)
+	}
+
+	file, err := store.GetFile(context.Background(), "drive", "Z")
+	if err != nil || file.Path != "/Folder A/Folder B/File Z" || file.MimeType != "image/png" {
+		t.Errorf("Unexpected file after partial sync: %+v, %v", file, err)
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	store := setupTest(t)
+
+	if _, err := store.DB.Exec("PRAGMA user_version=99"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := FromDB(store.DB); !errors.Is(err, ErrSchemaVersion) {
+		t.Errorf("Expected ErrSchemaVersion, got: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// GetFile returns the file with the provided ID within the Shared Drive of the driveID.
+func (store *Datastore) GetFile(ctx context.Context, driveID string, id string) (*ds.File, error) {
+	getParents, err := store.DB.PrepareContext(ctx, sqlGetAdditionalParents)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", sqlGetAdditionalParents, ErrInvalidStatement)
+	}
+
+	defer getParents.Close()
+	return store.getFile(ctx, getParents, driveID, id)
+}
+
+// GetFolder returns the folder with the provided ID within the Shared Drive of the driveID.
+func (store *Datastore) GetFolder(ctx context.Context, driveID string, id string) (*ds.Folder, error) {
+	getParents, err := store.DB.PrepareContext(ctx, sqlGetAdditionalParents)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", sqlGetAdditionalParents, ErrInvalidStatement)
+	}
+
+	defer getParents.Close()
+	return store.getFolder(ctx, getParents, driveID, id)
+}
+
+func (store *Datastore) getFile(ctx context.Context, getParents *sql.Stmt, driveID string, id string) (*ds.File, error) {
+	file := &ds.File{ID: id}
+
+	row := store.DB.QueryRowContext(ctx, sqlGetFileByID, id, driveID)
+	if err := scanFile(row, getParents, driveID, file); err != nil {
+		return nil, scanErr(ctx, err, id)
+	}
+
+	return file, nil
+}
+
+func (store *Datastore) getFolder(ctx context.Context, getParents *sql.Stmt, driveID string, id string) (*ds.Folder, error) {
+	folder := &ds.Folder{ID: id}
+
+	row := store.DB.QueryRowContext(ctx, sqlGetFolderByID, id, driveID)
+	if err := scanFolder(row, getParents, driveID, folder); err != nil {
+		return nil, scanErr(ctx, err, id)
+	}
+
+	return folder, nil
+}
+
+// ListChildren returns the folders and files within the folder, sorted on name and ID.
+// Items of which the folder is one of the additional parents are included as well.
+func (store *Datastore) ListChildren(ctx context.Context, driveID string, folderID string) (folders []ds.Folder, files []ds.File, err error) {
+	getParents, err := store.DB.PrepareContext(ctx, sqlGetAdditionalParents)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", sqlGetAdditionalParents, ErrInvalidStatement)
+	}
+
+	defer getParents.Close()
+
+	// Make sure the folder exists, as an empty folder has no children either.
+	if _, err = store.getFolder(ctx, getParents, driveID, folderID); err != nil {
+		return nil, nil, err
+	}
+
+	folderIDs, err := store.queryIDs(ctx, sqlListChildFolders, driveID, folderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, id := range folderIDs {
+		folder, err := store.getFolder(ctx, getParents, driveID, id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		folders = append(folders, *folder)
+	}
+
+	fileIDs, err := store.queryIDs(ctx, sqlListChildFiles, driveID, folderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, id := range fileIDs {
+		file, err := store.getFile(ctx, getParents, driveID, id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		files = append(files, *file)
+	}
+
+	return folders, files, nil
+}
+
+// queryIDs returns the IDs of the rows returned by the query.
+//
+// All rows are read before any other query is made,
+// as an in-memory database cannot be shared between connections.
+func (store *Datastore) queryIDs(ctx context.Context, query string, args ...interface{}) (ids []string, err error) {
+	rows, err := store.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, scanErr(ctx, err, query)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, scanErr(ctx, err, id)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, scanErr(ctx, err, query)
+	}
+
+	return ids, nil
+}
+
+// Lookup returns the file or folder at the slash-separated path,
+// relative to the root of the Shared Drive.
+//
+// Folders take precedence over files with the same name,
+// after which the item with the lowest ID is picked.
+func (store *Datastore) Lookup(ctx context.Context, driveID string, path string) (*ds.File, *ds.Folder, error) {
+	names := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+
+	id := driveID
+	isFolder := true
+
+	for _, name := range names {
+		// Only folders can have children.
+		if !isFolder {
+			return nil, nil, fmt.Errorf("%v: %w", path, ds.ErrNotFound)
+		}
+
+		row := store.DB.QueryRowContext(ctx, sqlLookupChild, driveID, name, id)
+		if err := row.Scan(&id, &isFolder); err != nil {
+			return nil, nil, scanErr(ctx, err, path)
+		}
+	}
+
+	if isFolder {
+		folder, err := store.GetFolder(ctx, driveID, id)
+		return nil, folder, err
+	}
+
+	file, err := store.GetFile(ctx, driveID, id)
+	return file, nil, err
+}
+
+const sqlListChildFolders = `
+SELECT id FROM folder
+WHERE drive=?1 AND (parent=?2 OR id IN (SELECT id FROM additional_parent WHERE drive=?1 AND parent=?2))
+ORDER BY name, id
+`
+
+const sqlListChildFiles = `
+SELECT id FROM file
+WHERE drive=?1 AND (parent=?2 OR id IN (SELECT id FROM additional_parent WHERE drive=?1 AND parent=?2))
+ORDER BY name, id
+`
+
+const sqlLookupChild = `
+SELECT id, isFolder FROM (
+	SELECT id, 1 AS isFolder FROM folder
+	WHERE drive=?1 AND name=?2 AND (parent=?3 OR id IN (SELECT id FROM additional_parent WHERE drive=?1 AND parent=?3))
+	UNION ALL
+	SELECT id, 0 AS isFolder FROM file
+	WHERE drive=?1 AND name=?2 AND (parent=?3 OR id IN (SELECT id FROM additional_parent WHERE drive=?1 AND parent=?3))
+)
+ORDER BY isFolder DESC, id
+LIMIT 1
+`
@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// ResolveShortcut returns the target of the shortcut with the provided ID.
+//
+// As a shortcut can point to a file or folder in another Shared Drive,
+// the target is looked up in all Shared Drives present in the datastore.
+// The Shared Drive of the shortcut takes precedence.
+func (store *Datastore) ResolveShortcut(ctx context.Context, driveID string, shortcutID string) (*ds.File, *ds.Folder, error) {
+	var targetID sql.NullString
+
+	row := store.DB.QueryRowContext(ctx, sqlGetShortcutTarget, shortcutID, driveID)
+	if err := row.Scan(&targetID); err != nil {
+		return nil, nil, scanErr(ctx, err, shortcutID)
+	}
+
+	if !targetID.Valid {
+		return nil, nil, fmt.Errorf("%v is not a shortcut: %w", shortcutID, ds.ErrNotFound)
+	}
+
+	var targetDrive string
+	var isFolder bool
+
+	row = store.DB.QueryRowContext(ctx, sqlLocateItem, targetID.String, driveID)
+	if err := row.Scan(&targetDrive, &isFolder); err != nil {
+		return nil, nil, scanErr(ctx, err, targetID.String)
+	}
+
+	getParents, err := store.DB.PrepareContext(ctx, sqlGetAdditionalParents)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", sqlGetAdditionalParents, ErrInvalidStatement)
+	}
+
+	defer getParents.Close()
+
+	if isFolder {
+		folder := &ds.Folder{ID: targetID.String}
+
+		row = store.DB.QueryRowContext(ctx, sqlGetFolderByID, targetID.String, targetDrive)
+		if err := scanFolder(row, getParents, targetDrive, folder); err != nil {
+			return nil, nil, scanErr(ctx, err, targetID.String)
+		}
+
+		return nil, folder, nil
+	}
+
+	file := &ds.File{ID: targetID.String}
+
+	row = store.DB.QueryRowContext(ctx, sqlGetFileByID, targetID.String, targetDrive)
+	if err := scanFile(row, getParents, targetDrive, file); err != nil {
+		return nil, nil, scanErr(ctx, err, targetID.String)
+	}
+
+	return file, nil, nil
+}
+
+// scanErr converts the error of a scan into ErrNotFound, ErrDatabase or the error of the context.
+func scanErr(ctx context.Context, err error, id string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%v: %w", id, ds.ErrNotFound)
+	}
+
+	return fmt.Errorf("%v scan: %w", id, ds.ErrDatabase)
+}
+
+const sqlGetShortcutTarget = `
+SELECT shortcutTargetId FROM file WHERE id=? AND drive=?
+`
+
+const sqlLocateItem = `
+SELECT drive, isFolder FROM (
+	SELECT drive, 0 AS isFolder FROM file WHERE id=?1
+	UNION ALL
+	SELECT drive, 1 AS isFolder FROM folder WHERE id=?1 AND parent IS NOT NULL
+)
+ORDER BY drive=?2 DESC
+LIMIT 1
+`
@@ -4,7 +4,11 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -15,14 +19,16 @@ import (
 )
 
 // New returns a Bernard Datastore with a SQLite3 backend.
+//
+// The tables of a database created by an older version of Bernard are migrated to the current Schema.
 func New(path string) (*Datastore, error) {
 	db, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return nil, fmt.Errorf("open: %w", ds.ErrDatabase)
 	}
 
-	if _, err := db.Exec(Schema); err != nil {
-		return nil, fmt.Errorf("schema: %w", ds.ErrDatabase)
+	if err := setupSchema(db); err != nil {
+		return nil, err
 	}
 
 	return &Datastore{DB: db}, nil
@@ -30,8 +36,8 @@ func New(path string) (*Datastore, error) {
 
 // FromDB returns a Bernard Datastore with the given SQLite3 backend.
 func FromDB(db *sql.DB) (*Datastore, error) {
-	if _, err := db.Exec(Schema); err != nil {
-		return nil, fmt.Errorf("schema: %w", ds.ErrDatabase)
+	if err := setupSchema(db); err != nil {
+		return nil, err
 	}
 
 	return &Datastore{DB: db}, nil
@@ -69,76 +75,472 @@ func addParameters(query string, items int) string {
 	return str.String()
 }
 
+// fileArgs returns the bind vars of a file in the column order of sqlUpsertFile and sqlStageFile.
+//
+// The shortcut columns are NULL when the file is not a shortcut.
+func fileArgs(driveID string, f ds.File) []interface{} {
+	var targetID, targetMimeType interface{}
+	if f.Shortcut != nil {
+		targetID = f.Shortcut.TargetID
+		targetMimeType = f.Shortcut.TargetMimeType
+	}
+
+	return []interface{}{
+		f.ID, driveID, f.Name, f.MD5, f.Parent, f.Size, f.Trashed,
+		f.SHA1, f.SHA256, f.MimeType, f.CreatedTime, f.ModifiedTime, f.Version,
+		f.FileExtension, f.OriginalFilename, f.HeadRevisionID, extraJSON(f.Extra),
+		targetID, targetMimeType,
+	}
+}
+
+// replaceParents replaces the additional parents of an item with the provided parents.
+func replaceParents(ctx context.Context, deleteParents, insertParent *sql.Stmt, driveID, id string, parents []string) error {
+	_, err := deleteParents.ExecContext(ctx, id, driveID)
+	if err != nil {
+		return err
+	}
+
+	for position, parent := range parents {
+		_, err = insertParent.ExecContext(ctx, id, driveID, parent, position)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updatePaths stores the path of every folder and file which has been added, renamed or moved,
+// including all descendants of such folders.
+//
+// Upserting a folder or file resets its path to NULL when its name or parent changes.
+// The paths of the descendants are reset first, after which the paths are rebuilt
+// one level of the hierarchy at a time, starting at the closest ancestors which still have a path.
+func updatePaths(ctx context.Context, tx *sql.Tx, driveID string) error {
+	for _, query := range []string{sqlResetFolderPaths, sqlResetFilePaths} {
+		_, err := tx.ExecContext(ctx, query, driveID)
+		if err != nil {
+			return fmt.Errorf("%v: %w", query, ErrInvalidStatement)
+		}
+	}
+
+	for {
+		result, err := tx.ExecContext(ctx, sqlUpdateFolderPaths, driveID)
+		if err != nil {
+			return fmt.Errorf("%v: %w", sqlUpdateFolderPaths, ErrInvalidStatement)
+		}
+
+		// All folders with a path to the root folder have been updated.
+		if updated, err := result.RowsAffected(); err != nil || updated == 0 {
+			break
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, sqlUpdateFilePaths, driveID)
+	if err != nil {
+		return fmt.Errorf("%v: %w", sqlUpdateFilePaths, ErrInvalidStatement)
+	}
+
+	return nil
+}
+
+// extraJSON stores the additional properties of a file or folder as a JSON object.
+// No additional properties are stored as an empty string.
+type extraJSON map[string]json.RawMessage
+
+// Value implements the driver.Valuer interface.
+func (extra extraJSON) Value() (driver.Value, error) {
+	if len(extra) == 0 {
+		return "", nil
+	}
+
+	b, err := json.Marshal(map[string]json.RawMessage(extra))
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (extra *extraJSON) Scan(src interface{}) error {
+	var b []byte
+
+	switch v := src.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("cannot scan %T into extra", src)
+	}
+
+	if len(b) == 0 {
+		*extra = nil
+		return nil
+	}
+
+	return json.Unmarshal(b, (*map[string]json.RawMessage)(extra))
+}
+
+// abort rolls back the transaction and returns the provided error,
+// unless the context has been cancelled in which case the context's error is returned.
+func abort(ctx context.Context, tx *sql.Tx, err error) error {
+	tx.Rollback()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
 // FullSync synchronises the provided Drive state to the datastore.
-func (store *Datastore) FullSync(drive ds.Drive, folders []ds.Folder, files []ds.File) (err error) {
-	// Start transaction so all statements can be rolled back.
-	tx, err := store.DB.Begin()
+func (store *Datastore) FullSync(drive ds.Drive, folders []ds.Folder, files []ds.File) error {
+	return store.FullSyncContext(context.Background(), drive, folders, files)
+}
+
+// FullSyncContext synchronises the provided Drive state to the datastore.
+//
+// The folders and files are staged and moved within a single transaction,
+// which is rolled back entirely on any error or when the context is cancelled.
+// Any staged pages of an interrupted resumable full sync of the same Drive are discarded.
+func (store *Datastore) FullSyncContext(ctx context.Context, drive ds.Drive, folders []ds.Folder, files []ds.File) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
 	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
 		return fmt.Errorf("begin: %w", ErrTransaction)
 	}
 
-	// Prepare sql statement to upsert folders.
-	upsertFolder, err := tx.Prepare(sqlUpsertFolder)
+	for _, query := range []string{sqlDeleteStagedFiles, sqlDeleteStagedFolders, sqlDeleteStagedParents, sqlDeleteCheckpoint} {
+		_, err = tx.ExecContext(ctx, query, drive.ID)
+		if err != nil {
+			return abort(ctx, tx, fmt.Errorf("%v: %w", query, ErrInvalidStatement))
+		}
+	}
+
+	err = stagePage(ctx, tx, drive.ID, folders, files)
 	if err != nil {
-		return fmt.Errorf("%v: %w", sqlUpsertFolder, ErrInvalidStatement)
+		return abort(ctx, tx, err)
 	}
 
-	// Prepare sql statement to upsert files.
-	upsertFile, err := tx.Prepare(sqlUpsertFile)
+	err = moveStaged(ctx, tx, drive)
 	if err != nil {
-		return fmt.Errorf("%v: %w", sqlUpsertFile, ErrInvalidStatement)
+		return abort(ctx, tx, err)
 	}
 
-	// Prepare sql statement to upsert a variable (pageToken).
-	upsertDrive, err := tx.Prepare(sqlUpsertDrive)
+	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("%v: %w", sqlUpsertDrive, ErrInvalidStatement)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return fmt.Errorf("commit: %w", ErrTransaction)
 	}
 
-	// Update the pageToken for future sync jobs.
-	// TODO(m-rots) error should not be a data anomaly
-	_, err = upsertDrive.Exec(drive.ID, drive.PageToken)
+	return nil
+}
+
+// BeginFullSync starts a resumable full sync of the provided Drive.
+//
+// Every written page is stored in the staging tables together with a checkpoint.
+// The staged folders and files are only moved to the folder and file tables on commit,
+// at which point the foreign key constraints are checked.
+//
+// Any staged pages of a previous full sync of the same Drive are discarded.
+func (store *Datastore) BeginFullSync(ctx context.Context, drive ds.Drive) (ds.FullSyncWriter, error) {
+	return store.beginFullSync(ctx, drive)
+}
+
+func (store *Datastore) beginFullSync(ctx context.Context, drive ds.Drive) (*fullSyncWriter, error) {
+	tx, err := store.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("pageToken: %w", ds.ErrDataAnomaly)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, fmt.Errorf("begin: %w", ErrTransaction)
 	}
 
-	// Insert the Shared Drive as the root folder.
-	_, err = upsertFolder.Exec(drive.ID, drive.ID, drive.Name, nil, false)
+	// Discard the pages of a previous full sync.
+	for _, query := range []string{sqlDeleteStagedFiles, sqlDeleteStagedFolders, sqlDeleteStagedParents} {
+		_, err = tx.ExecContext(ctx, query, drive.ID)
+		if err != nil {
+			return nil, abort(ctx, tx, fmt.Errorf("%v: %w", query, ErrInvalidStatement))
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, sqlUpsertCheckpoint, drive.ID, drive.Name, drive.PageToken, "", 0)
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("%v: %w", drive.ID, ds.ErrDataAnomaly)
+		return nil, abort(ctx, tx, fmt.Errorf("%v: %w", sqlUpsertCheckpoint, ErrInvalidStatement))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, fmt.Errorf("commit: %w", ErrTransaction)
+	}
+
+	return &fullSyncWriter{db: store.DB, drive: drive}, nil
+}
+
+// Checkpoint returns the checkpoint of the interrupted full sync of the driveID.
+func (store *Datastore) Checkpoint(ctx context.Context, driveID string) (ds.Checkpoint, error) {
+	checkpoint := ds.Checkpoint{
+		Drive: ds.Drive{ID: driveID},
+	}
+
+	row := store.DB.QueryRowContext(ctx, sqlGetCheckpoint, driveID)
+	err := row.Scan(&checkpoint.Drive.Name, &checkpoint.Drive.PageToken, &checkpoint.NextPageToken, &checkpoint.Pages)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ds.Checkpoint{}, ctx.Err()
+		}
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return ds.Checkpoint{}, ds.ErrNoCheckpoint
+		}
+
+		return ds.Checkpoint{}, fmt.Errorf("checkpoint scan: %w", ds.ErrDatabase)
+	}
+
+	return checkpoint, nil
+}
+
+// ResumeFullSync continues the full sync of the provided checkpoint.
+//
+// ErrNoCheckpoint is returned when the checkpoint does not match
+// the full sync in progress, for example as another full sync has begun since.
+func (store *Datastore) ResumeFullSync(ctx context.Context, checkpoint ds.Checkpoint) (ds.FullSyncWriter, error) {
+	current, err := store.Checkpoint(ctx, checkpoint.Drive.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if current != checkpoint {
+		return nil, ds.ErrNoCheckpoint
+	}
+
+	return &fullSyncWriter{db: store.DB, drive: checkpoint.Drive}, nil
+}
+
+// fullSyncWriter writes every page to the staging tables in its own transaction.
+type fullSyncWriter struct {
+	db    *sql.DB
+	drive ds.Drive
+}
+
+// WritePage stages the folders and files of a single page and updates the checkpoint.
+func (writer *fullSyncWriter) WritePage(ctx context.Context, folders []ds.Folder, files []ds.File, nextPageToken string) error {
+	tx, err := writer.db.BeginTx(ctx, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return fmt.Errorf("begin: %w", ErrTransaction)
+	}
+
+	err = stagePage(ctx, tx, writer.drive.ID, folders, files)
+	if err != nil {
+		return abort(ctx, tx, err)
+	}
+
+	_, err = tx.ExecContext(ctx, sqlAdvanceCheckpoint, nextPageToken, writer.drive.ID)
+	if err != nil {
+		return abort(ctx, tx, fmt.Errorf("%v: %w", sqlAdvanceCheckpoint, ErrInvalidStatement))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return fmt.Errorf("commit: %w", ErrTransaction)
+	}
+
+	return nil
+}
+
+// stagePage stages the folders and files of a single page within the transaction.
+func stagePage(ctx context.Context, tx *sql.Tx, driveID string, folders []ds.Folder, files []ds.File) error {
+	// Prepare sql statement to stage folders.
+	stageFolder, err := tx.PrepareContext(ctx, sqlStageFolder)
+	if err != nil {
+		return fmt.Errorf("%v: %w", sqlStageFolder, ErrInvalidStatement)
+	}
+
+	// Prepare sql statement to stage files.
+	stageFile, err := tx.PrepareContext(ctx, sqlStageFile)
+	if err != nil {
+		return fmt.Errorf("%v: %w", sqlStageFile, ErrInvalidStatement)
+	}
+
+	// Prepare sql statements to stage the additional parents.
+	unstageParents, err := tx.PrepareContext(ctx, sqlUnstageAdditionalParents)
+	if err != nil {
+		return fmt.Errorf("%v: %w", sqlUnstageAdditionalParents, ErrInvalidStatement)
+	}
+
+	stageParent, err := tx.PrepareContext(ctx, sqlStageAdditionalParent)
+	if err != nil {
+		return fmt.Errorf("%v: %w", sqlStageAdditionalParent, ErrInvalidStatement)
 	}
 
-	// Upsert all folders.
-	// Rollback when a data anomaly is detected (such as a FOREIGN KEY constraint).
 	for _, f := range folders {
-		_, err = upsertFolder.Exec(f.ID, drive.ID, f.Name, f.Parent, f.Trashed)
+		_, err = stageFolder.ExecContext(ctx, f.ID, driveID, f.Name, f.Parent, f.Trashed, extraJSON(f.Extra))
+		if err != nil {
+			return fmt.Errorf("%v: %w", f.ID, ds.ErrDatabase)
+		}
 
+		err = replaceParents(ctx, unstageParents, stageParent, driveID, f.ID, f.AdditionalParents)
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("%v: %w", f.ID, ds.ErrDataAnomaly)
+			return fmt.Errorf("%v: %w", f.ID, ds.ErrDatabase)
 		}
 	}
 
-	// Upsert all files.
-	// Rollback when a data anomaly is detected (such as a FOREIGN KEY constraint).
 	for _, f := range files {
-		_, err = upsertFile.Exec(f.ID, drive.ID, f.Name, f.MD5, f.Parent, f.Size, f.Trashed)
+		_, err = stageFile.ExecContext(ctx, fileArgs(driveID, f)...)
+		if err != nil {
+			return fmt.Errorf("%v: %w", f.ID, ds.ErrDatabase)
+		}
 
+		err = replaceParents(ctx, unstageParents, stageParent, driveID, f.ID, f.AdditionalParents)
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("%v: %w", f.ID, ds.ErrDataAnomaly)
+			return fmt.Errorf("%v: %w", f.ID, ds.ErrDatabase)
 		}
 	}
 
+	return nil
+}
+
+// Commit moves the staged folders and files to the folder and file tables
+// and saves the pageToken of the Drive.
+//
+// On a data anomaly, the staged pages and the checkpoint are discarded,
+// as resuming the full sync would result in the same anomaly.
+func (writer *fullSyncWriter) Commit(ctx context.Context) error {
+	tx, err := writer.db.BeginTx(ctx, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return fmt.Errorf("begin: %w", ErrTransaction)
+	}
+
+	err = moveStaged(ctx, tx, writer.drive)
+	if errors.Is(err, errForeignKey) {
+		tx.Rollback()
+		return writer.discard(ctx, err)
+	}
+
+	if err != nil {
+		return abort(ctx, tx, err)
+	}
+
 	err = tx.Commit()
 	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
 		return fmt.Errorf("commit: %w", ErrTransaction)
 	}
 
 	return nil
 }
 
+// errForeignKey occurs when the moved folders and files violate a FOREIGN KEY constraint.
+var errForeignKey = fmt.Errorf("foreign key: %w", ds.ErrDataAnomaly)
+
+// moveStaged moves the staged folders and files of the Drive to the folder and file tables
+// within the transaction, saves the pageToken and discards the checkpoint.
+//
+// errForeignKey is returned when the moved items violate a FOREIGN KEY constraint,
+// after which the transaction must be rolled back.
+func moveStaged(ctx context.Context, tx *sql.Tx, drive ds.Drive) error {
+	// Only check the foreign key constraints once all folders and files are moved.
+	_, err := tx.ExecContext(ctx, sqlDeferForeignKeys)
+	if err != nil {
+		return fmt.Errorf("%v: %w", sqlDeferForeignKeys, ErrInvalidStatement)
+	}
+
+	// Update the pageToken for future sync jobs.
+	_, err = tx.ExecContext(ctx, sqlUpsertDrive, drive.ID, drive.PageToken)
+	if err != nil {
+		return fmt.Errorf("pageToken: %w", ds.ErrDatabase)
+	}
+
+	// Insert the Shared Drive as the root folder.
+	_, err = tx.ExecContext(ctx, sqlUpsertFolder, drive.ID, drive.ID, drive.Name, nil, false, extraJSON(nil))
+	if err != nil {
+		return fmt.Errorf("%v: %w", drive.ID, ds.ErrDataAnomaly)
+	}
+
+	queries := []string{
+		sqlClearStagedParents, sqlMoveStagedFolders, sqlMoveStagedFiles, sqlMoveStagedParents,
+		sqlDeleteStagedFiles, sqlDeleteStagedFolders, sqlDeleteStagedParents, sqlDeleteCheckpoint,
+	}
+
+	for _, query := range queries {
+		_, err = tx.ExecContext(ctx, query, drive.ID)
+		if err != nil {
+			return fmt.Errorf("%v: %w", query, ErrInvalidStatement)
+		}
+	}
+
+	err = updatePaths(ctx, tx, drive.ID)
+	if err != nil {
+		return err
+	}
+
+	// SQLite keeps a transaction open when its commit fails on a deferred constraint.
+	// Therefore, the constraints are checked before the commit is issued.
+	rows, err := tx.QueryContext(ctx, sqlForeignKeyCheck)
+	if err != nil {
+		return fmt.Errorf("%v: %w", sqlForeignKeyCheck, ErrInvalidStatement)
+	}
+
+	// Any returned row is a violation of a FOREIGN KEY constraint.
+	violation := rows.Next()
+	rows.Close()
+
+	if violation {
+		return errForeignKey
+	}
+
+	return nil
+}
+
+// discard removes the staged pages and the checkpoint, and returns the provided error.
+func (writer *fullSyncWriter) discard(ctx context.Context, err error) error {
+	for _, query := range []string{sqlDeleteStagedFiles, sqlDeleteStagedFolders, sqlDeleteStagedParents, sqlDeleteCheckpoint} {
+		if _, execErr := writer.db.ExecContext(ctx, query, writer.drive.ID); execErr != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return fmt.Errorf("%v: %w", query, ErrInvalidStatement)
+		}
+	}
+
+	return err
+}
+
+// Rollback is a no-op, as every page is committed as soon as it is written.
+// The staged pages are kept so the full sync can be resumed.
+func (writer *fullSyncWriter) Rollback() error {
+	return nil
+}
+
 // PartialSync synchronises the provided changes to the datastore.
 //
 // 1. Update the pageToken and (if applicable) the name of the Shared Drive.
@@ -149,62 +551,95 @@ func (store *Datastore) FullSync(drive ds.Drive, folders []ds.Folder, files []ds
 //
 // 4. Remove any items of which the IDs match with the removedIDs slice.
 func (store *Datastore) PartialSync(drive ds.Drive, changedFolders []ds.Folder, changedFiles []ds.File, removedIDs []string) error {
-	tx, err := store.DB.Begin()
+	return store.PartialSyncContext(context.Background(), drive, changedFolders, changedFiles, removedIDs)
+}
+
+// PartialSyncContext synchronises the provided changes to the datastore.
+//
+// The transaction is rolled back when the context is cancelled.
+func (store *Datastore) PartialSyncContext(ctx context.Context, drive ds.Drive, changedFolders []ds.Folder, changedFiles []ds.File, removedIDs []string) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
 	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
 		return fmt.Errorf("begin: %w", ErrTransaction)
 	}
 
 	// Prepare sql statement to upsert folders.
-	upsertFolder, err := tx.Prepare(sqlUpsertFolder)
+	upsertFolder, err := tx.PrepareContext(ctx, sqlUpsertFolder)
 	if err != nil {
-		return fmt.Errorf("%v: %w", sqlUpsertFolder, ErrInvalidStatement)
+		return abort(ctx, tx, fmt.Errorf("%v: %w", sqlUpsertFolder, ErrInvalidStatement))
 	}
 
 	// Prepare sql statement to upsert files.
-	upsertFile, err := tx.Prepare(sqlUpsertFile)
+	upsertFile, err := tx.PrepareContext(ctx, sqlUpsertFile)
 	if err != nil {
-		return fmt.Errorf("%v: %w", sqlUpsertFile, ErrInvalidStatement)
+		return abort(ctx, tx, fmt.Errorf("%v: %w", sqlUpsertFile, ErrInvalidStatement))
+	}
+
+	// Prepare sql statements to replace the additional parents.
+	deleteParents, err := tx.PrepareContext(ctx, sqlDeleteAdditionalParents)
+	if err != nil {
+		return abort(ctx, tx, fmt.Errorf("%v: %w", sqlDeleteAdditionalParents, ErrInvalidStatement))
+	}
+
+	insertParent, err := tx.PrepareContext(ctx, sqlInsertAdditionalParent)
+	if err != nil {
+		return abort(ctx, tx, fmt.Errorf("%v: %w", sqlInsertAdditionalParent, ErrInvalidStatement))
 	}
 
 	// Prepare sql statement to upsert a variable (pageToken).
-	upsertDrive, err := tx.Prepare(sqlUpsertDrive)
+	upsertDrive, err := tx.PrepareContext(ctx, sqlUpsertDrive)
 	if err != nil {
-		return fmt.Errorf("%v: %w", sqlUpsertDrive, ErrInvalidStatement)
+		return abort(ctx, tx, fmt.Errorf("%v: %w", sqlUpsertDrive, ErrInvalidStatement))
 	}
 
 	// Update the pageToken for future sync jobs.
-	_, err = upsertDrive.Exec(drive.ID, drive.PageToken)
+	_, err = upsertDrive.ExecContext(ctx, drive.ID, drive.PageToken)
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("pageToken: %w", ds.ErrDataAnomaly)
+		return abort(ctx, tx, fmt.Errorf("pageToken: %w", ds.ErrDataAnomaly))
 	}
 
 	// Drive name is empty if not changed, so when not empty we should update it.
 	if drive.Name != "" {
-		_, err = upsertFolder.Exec(drive.ID, drive.ID, drive.Name, nil, false)
+		_, err = upsertFolder.ExecContext(ctx, drive.ID, drive.ID, drive.Name, nil, false, extraJSON(nil))
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("%v: %w", drive.ID, ds.ErrDataAnomaly)
+			return abort(ctx, tx, fmt.Errorf("%v: %w", drive.ID, ds.ErrDataAnomaly))
 		}
 	}
 
 	// upsert all changed folders and change childrens' trashed state
 	for _, f := range changedFolders {
-		_, err := upsertFolder.Exec(f.ID, drive.ID, f.Name, f.Parent, f.Trashed)
+		_, err := upsertFolder.ExecContext(ctx, f.ID, drive.ID, f.Name, f.Parent, f.Trashed, extraJSON(f.Extra))
 
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("%v: %w", f.ID, ds.ErrDataAnomaly)
+			return abort(ctx, tx, fmt.Errorf("%v: %w", f.ID, ds.ErrDataAnomaly))
 		}
 	}
 
 	// upsert all changed files
 	for _, f := range changedFiles {
-		_, err = upsertFile.Exec(f.ID, drive.ID, f.Name, f.MD5, f.Parent, f.Size, f.Trashed)
+		_, err = upsertFile.ExecContext(ctx, fileArgs(drive.ID, f)...)
+
+		if err != nil {
+			return abort(ctx, tx, fmt.Errorf("%v: %w", f.ID, ds.ErrDataAnomaly))
+		}
+	}
 
+	// replace the additional parents once all changed folders exist
+	for _, f := range changedFolders {
+		err = replaceParents(ctx, deleteParents, insertParent, drive.ID, f.ID, f.AdditionalParents)
+		if err != nil {
+			return abort(ctx, tx, fmt.Errorf("%v: %w", f.ID, ds.ErrDataAnomaly))
+		}
+	}
+
+	for _, f := range changedFiles {
+		err = replaceParents(ctx, deleteParents, insertParent, drive.ID, f.ID, f.AdditionalParents)
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("%v: %w", f.ID, ds.ErrDataAnomaly)
+			return abort(ctx, tx, fmt.Errorf("%v: %w", f.ID, ds.ErrDataAnomaly))
 		}
 	}
 
@@ -218,27 +653,44 @@ func (store *Datastore) PartialSync(drive ds.Drive, changedFolders []ds.Folder,
 		// append DriveID for the WHERE clause
 		args[len(removedIDs)] = drive.ID
 
+		// the additional parents of removed folders are deleted with the folder,
+		// but the removed items might still be linked to other parents
+		deleteRemovedParents := addParameters(sqlDeleteRemovedParents, len(removedIDs))
+
+		_, err = tx.ExecContext(ctx, deleteRemovedParents, args...)
+		if err != nil {
+			return abort(ctx, tx, fmt.Errorf("deleting additional parents: %w", ds.ErrDataAnomaly))
+		}
+
 		// first try to delete all files to prevent data anomalies
 		deleteFiles := addParameters(sqlDeleteFiles, len(removedIDs))
 
-		_, err = tx.Exec(deleteFiles, args...)
+		_, err = tx.ExecContext(ctx, deleteFiles, args...)
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("deleting files: %w", ds.ErrDataAnomaly)
+			return abort(ctx, tx, fmt.Errorf("deleting files: %w", ds.ErrDataAnomaly))
 		}
 
 		// then try to delete all folders, which should have no files as children now
 		deleteFolders := addParameters(sqlDeleteFolders, len(removedIDs))
 
-		_, err = tx.Exec(deleteFolders, args...)
+		_, err = tx.ExecContext(ctx, deleteFolders, args...)
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("deleting folders: %w", ds.ErrDataAnomaly)
+			return abort(ctx, tx, fmt.Errorf("deleting folders: %w", ds.ErrDataAnomaly))
 		}
 	}
 
+	// update the paths of all renamed and moved items
+	err = updatePaths(ctx, tx, drive.ID)
+	if err != nil {
+		return abort(ctx, tx, err)
+	}
+
 	err = tx.Commit()
 	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
 		return fmt.Errorf("commit: %w", ErrTransaction)
 	}
 
@@ -247,17 +699,28 @@ func (store *Datastore) PartialSync(drive ds.Drive, changedFolders []ds.Folder,
 
 // PageToken retrieves the pageToken the datastore currently reflects.
 func (store *Datastore) PageToken(driveID string) (string, error) {
+	return store.PageTokenContext(context.Background(), driveID)
+}
+
+// PageTokenContext retrieves the pageToken the datastore currently reflects.
+func (store *Datastore) PageTokenContext(ctx context.Context, driveID string) (string, error) {
 	var pageToken string
 
-	row := store.DB.QueryRow(sqlGetPageToken, driveID)
+	row := store.DB.QueryRowContext(ctx, sqlGetPageToken, driveID)
 	if err := row.Scan(&pageToken); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
 		return "", ds.ErrFullSync
 	}
 
 	return pageToken, nil
 }
 
-// Schema of the sqlite database
+// Schema of the sqlite database.
+//
+// Changes to the tables must be added to the migrations and increase the schemaVersion.
 const Schema string = `
 PRAGMA foreign_keys=ON;
 
@@ -269,6 +732,19 @@ CREATE TABLE IF NOT EXISTS file (
 	"size" integer NOT NULL,
 	"md5" text NOT NULL,
 	"trashed" boolean NOT NULL,
+	"sha1" text NOT NULL,
+	"sha256" text NOT NULL,
+	"mimeType" text NOT NULL,
+	"createdTime" datetime NOT NULL,
+	"modifiedTime" datetime NOT NULL,
+	"version" integer NOT NULL,
+	"fileExtension" text NOT NULL,
+	"originalFilename" text NOT NULL,
+	"headRevisionId" text NOT NULL,
+	"extra" text NOT NULL,
+	"shortcutTargetId" text,
+	"shortcutTargetMimeType" text,
+	"path" text,
 	PRIMARY KEY(id, drive),
 	FOREIGN KEY(parent, drive) REFERENCES folder(id, drive) DEFERRABLE INITIALLY IMMEDIATE
 );
@@ -279,17 +755,92 @@ CREATE TABLE IF NOT EXISTS folder (
   "name" text NOT NULL,
   "trashed" boolean NOT NULL,
 	"parent" text,
+	"extra" text NOT NULL,
+	"path" text,
 	PRIMARY KEY(id, drive),
   FOREIGN KEY(parent, drive) REFERENCES folder(id, drive) DEFERRABLE INITIALLY IMMEDIATE
 );
 
+CREATE INDEX IF NOT EXISTS file_parent ON file(parent, drive);
+CREATE INDEX IF NOT EXISTS file_stale_path ON file(drive) WHERE path IS NULL;
+
+CREATE INDEX IF NOT EXISTS folder_parent ON folder(parent, drive);
+CREATE INDEX IF NOT EXISTS folder_stale_path ON folder(drive) WHERE path IS NULL;
+
+CREATE TABLE IF NOT EXISTS additional_parent (
+	"id" text NOT NULL,
+	"drive" text NOT NULL,
+	"parent" text NOT NULL,
+	"position" integer NOT NULL,
+	PRIMARY KEY(id, drive, parent),
+	FOREIGN KEY(parent, drive) REFERENCES folder(id, drive) ON DELETE CASCADE DEFERRABLE INITIALLY IMMEDIATE
+);
+
 CREATE TABLE IF NOT EXISTS drive (
 	"id" text NOT NULL,
 	"pageToken" text NOT NULL,
 	PRIMARY KEY(id)
+);
+
+CREATE TABLE IF NOT EXISTS checkpoint (
+	"drive" text NOT NULL,
+	"name" text NOT NULL,
+	"pageToken" text NOT NULL,
+	"nextPageToken" text NOT NULL,
+	"pages" integer NOT NULL,
+	PRIMARY KEY(drive)
+);
+
+CREATE TABLE IF NOT EXISTS staged_file (
+	"id" text NOT NULL,
+	"drive" text NOT NULL,
+	"name" text NOT NULL,
+	"parent" text NOT NULL,
+	"size" integer NOT NULL,
+	"md5" text NOT NULL,
+	"trashed" boolean NOT NULL,
+	"sha1" text NOT NULL,
+	"sha256" text NOT NULL,
+	"mimeType" text NOT NULL,
+	"createdTime" datetime NOT NULL,
+	"modifiedTime" datetime NOT NULL,
+	"version" integer NOT NULL,
+	"fileExtension" text NOT NULL,
+	"originalFilename" text NOT NULL,
+	"headRevisionId" text NOT NULL,
+	"extra" text NOT NULL,
+	"shortcutTargetId" text,
+	"shortcutTargetMimeType" text,
+	PRIMARY KEY(id, drive)
+);
+
+CREATE TABLE IF NOT EXISTS staged_folder (
+	"id" text NOT NULL,
+	"drive" text NOT NULL,
+	"name" text NOT NULL,
+	"trashed" boolean NOT NULL,
+	"parent" text,
+	"extra" text NOT NULL,
+	PRIMARY KEY(id, drive)
+);
+
+CREATE TABLE IF NOT EXISTS staged_additional_parent (
+	"id" text NOT NULL,
+	"drive" text NOT NULL,
+	"parent" text NOT NULL,
+	"position" integer NOT NULL,
+	PRIMARY KEY(id, drive, parent)
 )
 `
 
+const sqlDeferForeignKeys = `
+PRAGMA defer_foreign_keys=ON
+`
+
+const sqlForeignKeyCheck = `
+PRAGMA foreign_key_check
+`
+
 const sqlUpsertDrive = `
 INSERT INTO drive (id, pageToken) VALUES (?, ?)
 	ON CONFLICT(id) DO UPDATE SET
@@ -297,21 +848,38 @@ INSERT INTO drive (id, pageToken) VALUES (?, ?)
 `
 
 const sqlUpsertFolder = `
-INSERT INTO folder (id, drive, name, parent, trashed) VALUES (?, ?, ?, NULLIF(?, ""), ?)
+INSERT INTO folder (id, drive, name, parent, trashed, extra) VALUES (?, ?, ?, NULLIF(?, ""), ?, ?)
 	ON CONFLICT(id, drive) DO UPDATE SET
 		name=excluded.name,
 		parent=excluded.parent,
-		trashed=excluded.trashed
+		trashed=excluded.trashed,
+		extra=excluded.extra,
+		path=CASE WHEN (name=excluded.name OR parent IS NULL) AND parent IS excluded.parent THEN path END
 `
 
 const sqlUpsertFile = `
-INSERT INTO file (id, drive, name, md5, parent, size, trashed) VALUES (?, ?, ?, ?, ?, ?, ?)
+INSERT INTO file (id, drive, name, md5, parent, size, trashed, sha1, sha256, mimeType, createdTime, modifiedTime, version, fileExtension, originalFilename, headRevisionId, extra,
+	shortcutTargetId, shortcutTargetMimeType)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	ON CONFLICT(id, drive) DO UPDATE SET
-    name=excluded.name,
-    md5=excluded.md5,
-    parent=excluded.parent,
-    size=excluded.size,
-		trashed=excluded.trashed
+		name=excluded.name,
+		md5=excluded.md5,
+		parent=excluded.parent,
+		size=excluded.size,
+		trashed=excluded.trashed,
+		sha1=excluded.sha1,
+		sha256=excluded.sha256,
+		mimeType=excluded.mimeType,
+		createdTime=excluded.createdTime,
+		modifiedTime=excluded.modifiedTime,
+		version=excluded.version,
+		fileExtension=excluded.fileExtension,
+		originalFilename=excluded.originalFilename,
+		headRevisionId=excluded.headRevisionId,
+		extra=excluded.extra,
+		shortcutTargetId=excluded.shortcutTargetId,
+		shortcutTargetMimeType=excluded.shortcutTargetMimeType,
+		path=CASE WHEN name=excluded.name AND parent=excluded.parent THEN path END
 `
 
 const sqlDeleteFiles = `
@@ -322,6 +890,179 @@ const sqlDeleteFolders = `
 DELETE FROM folder WHERE id IN (?) AND drive=?
 `
 
+const sqlDeleteAdditionalParents = `
+DELETE FROM additional_parent WHERE id=? AND drive=?
+`
+
+const sqlInsertAdditionalParent = `
+INSERT INTO additional_parent (id, drive, parent, position) VALUES (?, ?, ?, ?)
+	ON CONFLICT(id, drive, parent) DO UPDATE SET
+		position=excluded.position
+`
+
+const sqlDeleteRemovedParents = `
+DELETE FROM additional_parent WHERE id IN (?) AND drive=?
+`
+
+const sqlResetFolderPaths = `
+WITH RECURSIVE stale(id) AS (
+	SELECT id FROM folder WHERE drive=?1 AND path IS NULL
+	UNION
+	SELECT folder.id FROM folder JOIN stale ON folder.parent=stale.id WHERE folder.drive=?1
+)
+UPDATE folder SET path=NULL WHERE drive=?1 AND path IS NOT NULL AND id IN (SELECT id FROM stale)
+`
+
+const sqlResetFilePaths = `
+UPDATE file SET path=NULL
+WHERE drive=?1 AND path IS NOT NULL AND parent IN (SELECT id FROM folder WHERE drive=?1 AND path IS NULL)
+`
+
+const sqlUpdateFolderPaths = `
+UPDATE folder SET path=CASE WHEN parent IS NULL THEN '/' ELSE (
+	SELECT CASE p.path WHEN '/' THEN '' ELSE p.path END || '/' || folder.name
+	FROM folder AS p WHERE p.id=folder.parent AND p.drive=folder.drive
+) END
+WHERE drive=?1 AND path IS NULL AND (parent IS NULL OR EXISTS (
+	SELECT 1 FROM folder AS p WHERE p.id=folder.parent AND p.drive=folder.drive AND p.path IS NOT NULL
+))
+`
+
+const sqlUpdateFilePaths = `
+UPDATE file SET path=(
+	SELECT CASE p.path WHEN '/' THEN '' ELSE p.path END || '/' || file.name
+	FROM folder AS p WHERE p.id=file.parent AND p.drive=file.drive
+)
+WHERE drive=?1 AND path IS NULL
+`
+
 const sqlGetPageToken = `
 SELECT pageToken FROM drive WHERE id=?
 `
+
+const sqlUpsertCheckpoint = `
+INSERT INTO checkpoint (drive, name, pageToken, nextPageToken, pages) VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT(drive) DO UPDATE SET
+		name=excluded.name,
+		pageToken=excluded.pageToken,
+		nextPageToken=excluded.nextPageToken,
+		pages=excluded.pages
+`
+
+const sqlAdvanceCheckpoint = `
+UPDATE checkpoint SET nextPageToken=?, pages=pages+1 WHERE drive=?
+`
+
+const sqlGetCheckpoint = `
+SELECT name, pageToken, nextPageToken, pages FROM checkpoint WHERE drive=?
+`
+
+const sqlDeleteCheckpoint = `
+DELETE FROM checkpoint WHERE drive=?
+`
+
+const sqlStageFolder = `
+INSERT INTO staged_folder (id, drive, name, parent, trashed, extra) VALUES (?, ?, ?, NULLIF(?, ""), ?, ?)
+	ON CONFLICT(id, drive) DO UPDATE SET
+		name=excluded.name,
+		parent=excluded.parent,
+		trashed=excluded.trashed,
+		extra=excluded.extra
+`
+
+const sqlStageFile = `
+INSERT INTO staged_file (id, drive, name, md5, parent, size, trashed, sha1, sha256, mimeType, createdTime, modifiedTime, version, fileExtension, originalFilename, headRevisionId, extra,
+	shortcutTargetId, shortcutTargetMimeType)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(id, drive) DO UPDATE SET
+		name=excluded.name,
+		md5=excluded.md5,
+		parent=excluded.parent,
+		size=excluded.size,
+		trashed=excluded.trashed,
+		sha1=excluded.sha1,
+		sha256=excluded.sha256,
+		mimeType=excluded.mimeType,
+		createdTime=excluded.createdTime,
+		modifiedTime=excluded.modifiedTime,
+		version=excluded.version,
+		fileExtension=excluded.fileExtension,
+		originalFilename=excluded.originalFilename,
+		headRevisionId=excluded.headRevisionId,
+		extra=excluded.extra,
+		shortcutTargetId=excluded.shortcutTargetId,
+		shortcutTargetMimeType=excluded.shortcutTargetMimeType
+`
+
+const sqlMoveStagedFolders = `
+INSERT INTO folder (id, drive, name, parent, trashed, extra)
+	SELECT id, drive, name, parent, trashed, extra FROM staged_folder WHERE drive=?
+	ON CONFLICT(id, drive) DO UPDATE SET
+		name=excluded.name,
+		parent=excluded.parent,
+		trashed=excluded.trashed,
+		extra=excluded.extra,
+		path=CASE WHEN (name=excluded.name OR parent IS NULL) AND parent IS excluded.parent THEN path END
+`
+
+const sqlMoveStagedFiles = `
+INSERT INTO file (id, drive, name, md5, parent, size, trashed, sha1, sha256, mimeType, createdTime, modifiedTime, version, fileExtension, originalFilename, headRevisionId, extra,
+	shortcutTargetId, shortcutTargetMimeType)
+	SELECT id, drive, name, md5, parent, size, trashed, sha1, sha256, mimeType, createdTime, modifiedTime, version, fileExtension, originalFilename, headRevisionId, extra,
+	shortcutTargetId, shortcutTargetMimeType FROM staged_file WHERE drive=?
+	ON CONFLICT(id, drive) DO UPDATE SET
+		name=excluded.name,
+		md5=excluded.md5,
+		parent=excluded.parent,
+		size=excluded.size,
+		trashed=excluded.trashed,
+		sha1=excluded.sha1,
+		sha256=excluded.sha256,
+		mimeType=excluded.mimeType,
+		createdTime=excluded.createdTime,
+		modifiedTime=excluded.modifiedTime,
+		version=excluded.version,
+		fileExtension=excluded.fileExtension,
+		originalFilename=excluded.originalFilename,
+		headRevisionId=excluded.headRevisionId,
+		extra=excluded.extra,
+		shortcutTargetId=excluded.shortcutTargetId,
+		shortcutTargetMimeType=excluded.shortcutTargetMimeType,
+		path=CASE WHEN name=excluded.name AND parent=excluded.parent THEN path END
+`
+
+const sqlDeleteStagedFolders = `
+DELETE FROM staged_folder WHERE drive=?
+`
+
+const sqlDeleteStagedFiles = `
+DELETE FROM staged_file WHERE drive=?
+`
+
+const sqlUnstageAdditionalParents = `
+DELETE FROM staged_additional_parent WHERE id=? AND drive=?
+`
+
+const sqlStageAdditionalParent = `
+INSERT INTO staged_additional_parent (id, drive, parent, position) VALUES (?, ?, ?, ?)
+	ON CONFLICT(id, drive, parent) DO UPDATE SET
+		position=excluded.position
+`
+
+const sqlClearStagedParents = `
+DELETE FROM additional_parent WHERE drive=?1 AND (
+	id IN (SELECT id FROM staged_folder WHERE drive=?1) OR
+	id IN (SELECT id FROM staged_file WHERE drive=?1)
+)
+`
+
+const sqlMoveStagedParents = `
+INSERT INTO additional_parent (id, drive, parent, position)
+	SELECT id, drive, parent, position FROM staged_additional_parent WHERE drive=?
+	ON CONFLICT(id, drive, parent) DO UPDATE SET
+		position=excluded.position
+`
+
+const sqlDeleteStagedParents = `
+DELETE FROM staged_additional_parent WHERE drive=?
+`
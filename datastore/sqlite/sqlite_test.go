@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"testing"
@@ -274,6 +275,290 @@ func TestFullSync(t *testing.T) {
 	}
 }
 
+func TestStreamingFullSync(t *testing.T) {
+	type page struct {
+		folders []ds.Folder
+		files   []ds.File
+	}
+
+	type test struct {
+		name    string
+		pages   []page
+		err     error
+		folders []ds.Folder
+		files   []ds.File
+	}
+
+	drive := ds.Drive{
+		ID:        "drive",
+		Name:      "streaming",
+		PageToken: "page token :)",
+	}
+
+	driveFolder := ds.Folder{ID: drive.ID, Name: drive.Name}
+
+	var testCases = []test{
+		{
+			name: "parents in later pages",
+			pages: []page{
+				{
+					files: []ds.File{{ID: "Z", Parent: "B"}},
+				},
+				{
+					folders: []ds.Folder{{ID: "B", Parent: "A"}},
+				},
+				{
+					folders: []ds.Folder{{ID: "A", Parent: drive.ID}},
+				},
+			},
+			folders: []ds.Folder{
+				driveFolder,
+				{ID: "B", Parent: "A"},
+				{ID: "A", Parent: drive.ID},
+			},
+			files: []ds.File{
+				{ID: "Z", Parent: "B"},
+			},
+		},
+		{
+			name: "unknown parent -> data anomaly",
+			pages: []page{
+				{
+					folders: []ds.Folder{{ID: "A", Parent: drive.ID}},
+				},
+				{
+					files: []ds.File{{ID: "Z", Parent: "unknown parent"}},
+				},
+			},
+			err: ds.ErrDataAnomaly,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := setupTest(t)
+			ctx := context.Background()
+
+			writer, err := store.BeginFullSync(ctx, drive)
+			if err != nil {
+				t.Fatalf("Could not begin full sync: %s", err.Error())
+			}
+
+			for _, p := range tc.pages {
+				err = writer.WritePage(ctx, p.folders, p.files, "")
+				if err != nil {
+					t.Fatalf("Could not write page: %s", err.Error())
+				}
+			}
+
+			err = writer.Commit(ctx)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			files := getFiles(t, store)
+			if !reflect.DeepEqual(files, tc.files) {
+				t.Log(files)
+				t.Log(tc.files)
+				t.Errorf("Files to not match")
+			}
+
+			folders := getFolders(t, store)
+			if !reflect.DeepEqual(folders, tc.folders) {
+				t.Log(folders)
+				t.Log(tc.folders)
+				t.Errorf("Folders to not match")
+			}
+		})
+	}
+}
+
+func TestResumeFullSync(t *testing.T) {
+	store := setupTest(t)
+	ctx := context.Background()
+
+	drive := ds.Drive{ID: "drive", Name: "resumable", PageToken: "1"}
+
+	_, err := store.Checkpoint(ctx, drive.ID)
+	if !errors.Is(err, ds.ErrNoCheckpoint) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	writer, err := store.BeginFullSync(ctx, drive)
+	if err != nil {
+		t.Fatalf("Could not begin full sync: %s", err.Error())
+	}
+
+	err = writer.WritePage(ctx, nil, []ds.File{{ID: "Z", Parent: "A"}}, "page 2")
+	if err != nil {
+		t.Fatalf("Could not write page: %s", err.Error())
+	}
+
+	// interrupt the full sync
+	if err = writer.Rollback(); err != nil {
+		t.Fatalf("Could not roll back: %s", err.Error())
+	}
+
+	if files := getFiles(t, store); len(files) != 0 {
+		t.Errorf("Files visible before commit: %v", files)
+	}
+
+	checkpoint, err := store.Checkpoint(ctx, drive.ID)
+	if err != nil {
+		t.Fatalf("Could not get checkpoint: %s", err.Error())
+	}
+
+	expected := ds.Checkpoint{Drive: drive, NextPageToken: "page 2", Pages: 1}
+	if checkpoint != expected {
+		t.Log(checkpoint)
+		t.Log(expected)
+		t.Fatalf("Checkpoints do not match")
+	}
+
+	writer, err = store.ResumeFullSync(ctx, checkpoint)
+	if err != nil {
+		t.Fatalf("Could not resume full sync: %s", err.Error())
+	}
+
+	err = writer.WritePage(ctx, []ds.Folder{{ID: "A", Parent: drive.ID}}, nil, "")
+	if err != nil {
+		t.Fatalf("Could not write page: %s", err.Error())
+	}
+
+	// the checkpoint of the first page is outdated now
+	if _, err = store.ResumeFullSync(ctx, checkpoint); !errors.Is(err, ds.ErrNoCheckpoint) {
+		t.Errorf("Unexpected error when resuming an outdated checkpoint: %v", err)
+	}
+
+	if err = writer.Commit(ctx); err != nil {
+		t.Fatalf("Could not commit: %s", err.Error())
+	}
+
+	if _, err = store.Checkpoint(ctx, drive.ID); !errors.Is(err, ds.ErrNoCheckpoint) {
+		t.Errorf("Checkpoint left behind after commit: %v", err)
+	}
+
+	pageToken, err := store.PageToken(drive.ID)
+	if err != nil || pageToken != drive.PageToken {
+		t.Errorf("pageTokens do not match")
+	}
+
+	files := getFiles(t, store)
+	if !reflect.DeepEqual(files, []ds.File{{ID: "Z", Parent: "A"}}) {
+		t.Errorf("Files do not match: %v", files)
+	}
+}
+
+func TestDiscardOnDataAnomaly(t *testing.T) {
+	store := setupTest(t)
+	ctx := context.Background()
+
+	drive := ds.Drive{ID: "drive", PageToken: "1"}
+
+	writer, err := store.BeginFullSync(ctx, drive)
+	if err != nil {
+		t.Fatalf("Could not begin full sync: %s", err.Error())
+	}
+
+	err = writer.WritePage(ctx, nil, []ds.File{{ID: "Z", Parent: "unknown parent"}}, "")
+	if err != nil {
+		t.Fatalf("Could not write page: %s", err.Error())
+	}
+
+	if err = writer.Commit(ctx); !errors.Is(err, ds.ErrDataAnomaly) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err = store.Checkpoint(ctx, drive.ID); !errors.Is(err, ds.ErrNoCheckpoint) {
+		t.Errorf("Checkpoint left behind after data anomaly: %v", err)
+	}
+
+	if _, err = store.PageToken(drive.ID); !errors.Is(err, ds.ErrFullSync) {
+		t.Errorf("PageToken saved after data anomaly")
+	}
+}
+
+func TestFullSyncRollback(t *testing.T) {
+	store := setupTest(t)
+	ctx := context.Background()
+
+	drive := ds.Drive{ID: "drive", PageToken: "1"}
+	anomaly := []ds.File{{ID: "Z", Parent: "unknown parent"}}
+
+	// A failed full sync does not leave a checkpoint behind.
+	if err := store.FullSync(drive, nil, anomaly); !errors.Is(err, ds.ErrDataAnomaly) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := store.Checkpoint(ctx, drive.ID); !errors.Is(err, ds.ErrNoCheckpoint) {
+		t.Errorf("Checkpoint left behind after a failed full sync: %v", err)
+	}
+
+	// Nor does it touch the checkpoint of an interrupted resumable full sync.
+	writer, err := store.BeginFullSync(ctx, drive)
+	if err != nil {
+		t.Fatalf("Could not begin full sync: %s", err.Error())
+	}
+
+	if err = writer.WritePage(ctx, []ds.Folder{{ID: "A", Parent: drive.ID}}, nil, "page 2"); err != nil {
+		t.Fatalf("Could not write page: %s", err.Error())
+	}
+
+	if err = store.FullSync(ds.Drive{ID: drive.ID, PageToken: "2"}, nil, anomaly); !errors.Is(err, ds.ErrDataAnomaly) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	checkpoint, err := store.Checkpoint(ctx, drive.ID)
+	if expected := (ds.Checkpoint{Drive: drive, NextPageToken: "page 2", Pages: 1}); err != nil || checkpoint != expected {
+		t.Errorf("Checkpoint changed by a failed full sync: %v, %v", checkpoint, err)
+	}
+
+	if folders := getFolders(t, store); len(folders) != 0 {
+		t.Errorf("Folders left behind after a failed full sync: %v", folders)
+	}
+
+	// A successful full sync discards the interrupted full sync.
+	if err = store.FullSync(ds.Drive{ID: drive.ID, PageToken: "2"}, nil, nil); err != nil {
+		t.Fatalf("Unexpected error at full sync: %v", err)
+	}
+
+	if _, err = store.Checkpoint(ctx, drive.ID); !errors.Is(err, ds.ErrNoCheckpoint) {
+		t.Errorf("Checkpoint left behind after full sync: %v", err)
+	}
+
+	if folders := getFolders(t, store); !reflect.DeepEqual(folders, []ds.Folder{{ID: drive.ID}}) {
+		t.Errorf("Staged folders of the interrupted full sync were committed: %v", folders)
+	}
+}
+
+func TestCancelledSync(t *testing.T) {
+	store := setupTest(t)
+
+	drive := ds.Drive{ID: "drive", Name: "cancelled", PageToken: "1"}
+	folders := []ds.Folder{{ID: "A", Parent: drive.ID}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := store.FullSyncContext(ctx, drive, folders, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if folders := getFolders(t, store); len(folders) != 0 {
+		t.Errorf("Folders left behind after cancellation: %v", folders)
+	}
+
+	err = store.PartialSyncContext(ctx, drive, folders, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := store.PageToken(drive.ID); !errors.Is(err, ds.ErrFullSync) {
+		t.Errorf("PageToken saved after cancellation")
+	}
+}
+
 func TestPartialSync(t *testing.T) {
 	type State struct {
 		files   []ds.File
@@ -521,3 +806,238 @@ func TestPartialSync(t *testing.T) {
 		})
 	}
 }
+
+func getAdditionalParents(t *testing.T, store *Datastore) map[string][]string {
+	t.Helper()
+
+	rows, err := store.DB.Query("SELECT id, parent FROM additional_parent ORDER BY id, position")
+	if err != nil {
+		t.Fatalf("Could not query additional parent rows: %s", err.Error())
+	}
+
+	parents := make(map[string][]string)
+
+	defer rows.Close()
+	for rows.Next() {
+		var id, parent string
+
+		err = rows.Scan(&id, &parent)
+		if err != nil {
+			t.Fatalf("Error when scanning additional parent rows: %s", err.Error())
+		}
+
+		parents[id] = append(parents[id], parent)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		t.Fatalf("Error when doing final row error check: %s", err.Error())
+	}
+
+	return parents
+}
+
+func TestAdditionalParents(t *testing.T) {
+	store := setupTest(t)
+
+	drive := ds.Drive{ID: "drive", Name: "Shared Drive", PageToken: "1"}
+
+	// an unknown additional parent is a data anomaly
+	err := store.FullSync(drive, nil, []ds.File{
+		{ID: "Z", Parent: "drive", AdditionalParents: []string{"unknown parent"}},
+	})
+
+	if !errors.Is(err, ds.ErrDataAnomaly) {
+		t.Fatalf("Expected a data anomaly, got: %v", err)
+	}
+
+	err = store.FullSync(drive,
+		[]ds.Folder{
+			{ID: "A", Parent: "drive"},
+			{ID: "B", Parent: "drive"},
+			{ID: "C", Parent: "A", AdditionalParents: []string{"B"}},
+		},
+		[]ds.File{
+			{ID: "Z", Parent: "A", AdditionalParents: []string{"C", "B"}},
+			{ID: "Y", Parent: "A", AdditionalParents: []string{"B"}},
+		},
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error at full sync: %s", err.Error())
+	}
+
+	expected := map[string][]string{
+		"C": {"B"},
+		"Y": {"B"},
+		"Z": {"C", "B"},
+	}
+
+	if parents := getAdditionalParents(t, store); !reflect.DeepEqual(parents, expected) {
+		t.Log(parents)
+		t.Errorf("Additional parents do not match after the full sync")
+	}
+
+	// Z loses an additional parent, Y is removed and B is removed as an additional parent of C.
+	drive.PageToken = "2"
+	err = store.PartialSync(drive,
+		[]ds.Folder{{ID: "C", Parent: "A"}},
+		[]ds.File{{ID: "Z", Parent: "A", AdditionalParents: []string{"B"}}},
+		[]string{"Y"},
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error at partial sync: %s", err.Error())
+	}
+
+	expected = map[string][]string{
+		"Z": {"B"},
+	}
+
+	if parents := getAdditionalParents(t, store); !reflect.DeepEqual(parents, expected) {
+		t.Log(parents)
+		t.Errorf("Additional parents do not match after the partial sync")
+	}
+
+	// Removing a folder removes it as an additional parent.
+	drive.PageToken = "3"
+	err = store.PartialSync(drive, nil, nil, []string{"B"})
+	if err != nil {
+		t.Fatalf("Unexpected error at partial sync: %s", err.Error())
+	}
+
+	if parents := getAdditionalParents(t, store); len(parents) != 0 {
+		t.Log(parents)
+		t.Errorf("Additional parents should be removed with their folder")
+	}
+}
+
+func getPaths(t *testing.T, store *Datastore) map[string]string {
+	t.Helper()
+
+	rows, err := store.DB.Query("SELECT id, IFNULL(path, '') FROM folder UNION ALL SELECT id, IFNULL(path, '') FROM file")
+	if err != nil {
+		t.Fatalf("Could not query path rows: %s", err.Error())
+	}
+
+	paths := make(map[string]string)
+
+	defer rows.Close()
+	for rows.Next() {
+		var id, path string
+
+		err = rows.Scan(&id, &path)
+		if err != nil {
+			t.Fatalf("Error when scanning path rows: %s", err.Error())
+		}
+
+		paths[id] = path
+	}
+
+	err = rows.Err()
+	if err != nil {
+		t.Fatalf("Error when doing final row error check: %s", err.Error())
+	}
+
+	return paths
+}
+
+func TestPaths(t *testing.T) {
+	store := setupTest(t)
+
+	drive := ds.Drive{ID: "drive", Name: "Shared Drive", PageToken: "1"}
+
+	// children before their parents
+	err := store.FullSync(drive,
+		[]ds.Folder{
+			{ID: "B", Name: "2020", Parent: "A"},
+			{ID: "A", Name: "Movies", Parent: "drive"},
+			{ID: "C", Name: "Series", Parent: "drive"},
+		},
+		[]ds.File{
+			{ID: "Z", Name: "foo.mkv", Parent: "B", AdditionalParents: []string{"C"}},
+			{ID: "Y", Name: "readme.txt", Parent: "drive"},
+			{ID: "X", Name: "bar.mkv", Parent: "C"},
+		},
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error at full sync: %s", err.Error())
+	}
+
+	expected := map[string]string{
+		"drive": "/",
+		"A":     "/Movies",
+		"B":     "/Movies/2020",
+		"C":     "/Series",
+		"Z":     "/Movies/2020/foo.mkv",
+		"Y":     "/readme.txt",
+		"X":     "/Series/bar.mkv",
+	}
+
+	if paths := getPaths(t, store); !reflect.DeepEqual(paths, expected) {
+		t.Log(paths)
+		t.Errorf("Paths do not match after the full sync")
+	}
+
+	// A is renamed, C is moved into a new folder D and Y is trashed.
+	// The name of the Shared Drive is not part of any path.
+	drive = ds.Drive{ID: "drive", Name: "Renamed Shared Drive", PageToken: "2"}
+	err = store.PartialSync(drive,
+		[]ds.Folder{
+			{ID: "A", Name: "Films", Parent: "drive"},
+			{ID: "D", Name: "TV", Parent: "A"},
+			{ID: "C", Name: "Series", Parent: "D"},
+		},
+		[]ds.File{
+			{ID: "Y", Name: "readme.txt", Parent: "drive", Trashed: true},
+		},
+		nil,
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error at partial sync: %s", err.Error())
+	}
+
+	expected = map[string]string{
+		"drive": "/",
+		"A":     "/Films",
+		"B":     "/Films/2020",
+		"C":     "/Films/TV/Series",
+		"D":     "/Films/TV",
+		"Z":     "/Films/2020/foo.mkv",
+		"Y":     "/readme.txt",
+		"X":     "/Films/TV/Series/bar.mkv",
+	}
+
+	if paths := getPaths(t, store); !reflect.DeepEqual(paths, expected) {
+		t.Log(paths)
+		t.Errorf("Paths do not match after renaming and moving folders")
+	}
+
+	// B is moved to the root folder and Z is renamed, while Y and X are removed.
+	drive.PageToken = "3"
+	err = store.PartialSync(drive,
+		[]ds.Folder{{ID: "B", Name: "2020", Parent: "drive"}},
+		[]ds.File{{ID: "Z", Name: "baz.mkv", Parent: "B"}},
+		[]string{"Y", "X"},
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error at partial sync: %s", err.Error())
+	}
+
+	expected = map[string]string{
+		"drive": "/",
+		"A":     "/Films",
+		"B":     "/2020",
+		"C":     "/Films/TV/Series",
+		"D":     "/Films/TV",
+		"Z":     "/2020/baz.mkv",
+	}
+
+	if paths := getPaths(t, store); !reflect.DeepEqual(paths, expected) {
+		t.Log(paths)
+		t.Errorf("Paths do not match after moving a folder to the root")
+	}
+}
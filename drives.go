@@ -0,0 +1,85 @@
+package bernard
+
+import (
+	"context"
+	"errors"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// ListDrives returns all Shared Drives the Authenticator has access to.
+//
+// The returned Drives only contain an ID and a Name.
+func (bernard *Bernard) ListDrives() ([]ds.Drive, error) {
+	return bernard.ListDrivesContext(context.Background())
+}
+
+// ListDrivesContext returns all Shared Drives the Authenticator has access to.
+//
+// The returned Drives only contain an ID and a Name.
+func (bernard *Bernard) ListDrivesContext(ctx context.Context) ([]ds.Drive, error) {
+	return bernard.fetch.drives(ctx)
+}
+
+// A SyncResult reports the outcome of synchronising a single Shared Drive with SyncAll.
+type SyncResult struct {
+	// Drive is the synchronised Shared Drive as returned by ListDrives.
+	Drive ds.Drive
+
+	// FullSync is true when the Shared Drive was not present in the datastore yet,
+	// and has been fully synchronised instead of partially.
+	FullSync bool
+
+	// Err is the error the synchronisation of this Shared Drive resulted in, if any.
+	Err error
+}
+
+// SyncAll synchronises every Shared Drive the Authenticator has access to.
+//
+// Shared Drives which are not present in the datastore yet are fully synchronised,
+// all other Shared Drives are partially synchronised with the provided hooks.
+func (bernard *Bernard) SyncAll(hooks ...Hook) ([]SyncResult, error) {
+	return bernard.SyncAllContext(context.Background(), hooks...)
+}
+
+// SyncAllContext synchronises every Shared Drive the Authenticator has access to.
+//
+// Shared Drives which are not present in the datastore yet are fully synchronised,
+// all other Shared Drives are partially synchronised with the provided hooks.
+//
+// An error in the synchronisation of one Shared Drive does not stop the synchronisation
+// of the others. Instead, the error is reported in the SyncResult of that Shared Drive.
+// An error is only returned when the Shared Drives cannot be listed,
+// or when the context is cancelled. In the latter case, the results of the
+// Shared Drives synchronised so far are returned as well.
+func (bernard *Bernard) SyncAllContext(ctx context.Context, hooks ...Hook) ([]SyncResult, error) {
+	drives, err := bernard.ListDrivesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SyncResult, 0, len(drives))
+
+	for _, drive := range drives {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		result := SyncResult{Drive: drive}
+
+		_, err := bernard.storePageToken(ctx, drive.ID)
+		switch {
+		case errors.Is(err, ds.ErrFullSync):
+			result.FullSync = true
+			result.Err = bernard.FullSyncContext(ctx, drive.ID)
+		case err != nil:
+			result.Err = err
+		default:
+			result.Err = bernard.PartialSyncContext(ctx, drive.ID, hooks...)
+		}
+
+		results = append(results, result)
+	}
+
+	return results, ctx.Err()
+}
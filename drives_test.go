@@ -0,0 +1,114 @@
+package bernard
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+func drivesHandler(files http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/drives":
+			fixturePath := "testdata/drives/pageToken.json"
+			if pageToken := r.URL.Query().Get("pageToken"); pageToken != "" {
+				fixturePath = pageToken
+			}
+
+			http.ServeFile(w, r, fixturePath)
+		case "/changes":
+			http.ServeFile(w, r, r.URL.Query().Get("pageToken"))
+		default:
+			files(w, r)
+		}
+	}
+}
+
+func TestListDrives(t *testing.T) {
+	handler := drivesHandler(http.NotFound)
+
+	fetch, server, _ := setupTest(handler)
+	defer server.Close()
+
+	bernard := &Bernard{fetch: fetch, store: newMockStore()}
+
+	drives, err := bernard.ListDrives()
+	if err != nil {
+		t.Fatalf("ListDrives returned an error: %s", err.Error())
+	}
+
+	expected := []ds.Drive{
+		{ID: "known", Name: "Known Drive"},
+		{ID: "new", Name: "New Drive"},
+		{ID: "broken", Name: "Broken Drive"},
+	}
+
+	if !reflect.DeepEqual(drives, expected) {
+		t.Log(drives)
+		t.Log(expected)
+		t.Error("Drives do not match the expected output")
+	}
+}
+
+func TestSyncAll(t *testing.T) {
+	files := fixtureHandler("testdata/all-content/order.json")
+	handler := drivesHandler(func(w http.ResponseWriter, r *http.Request) {
+		// the broken Shared Drive cannot be found
+		if r.URL.Query().Get("driveId") == "broken" {
+			w.WriteHeader(404)
+			return
+		}
+
+		files(w, r)
+	})
+
+	fetch, server, _ := setupTest(handler)
+	defer server.Close()
+
+	store := newMockStore()
+	store.pageTokens["known"] = "testdata/changed-content/fields.json"
+
+	var hooked []string
+	hook := func(drive ds.Drive, files []ds.File, folders []ds.Folder, removed []string) error {
+		hooked = append(hooked, drive.ID)
+		return nil
+	}
+
+	bernard := &Bernard{fetch: fetch, store: store}
+
+	results, err := bernard.SyncAll(hook)
+	if err != nil {
+		t.Fatalf("SyncAll returned an error: %s", err.Error())
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+
+	if results[0].FullSync || results[0].Err != nil {
+		t.Errorf("Known drive should be partially synchronised: %+v", results[0])
+	}
+
+	if !results[1].FullSync || results[1].Err != nil {
+		t.Errorf("New drive should be fully synchronised: %+v", results[1])
+	}
+
+	if !results[2].FullSync || !errors.Is(results[2].Err, ErrNotFound) {
+		t.Errorf("Broken drive should report its error: %+v", results[2])
+	}
+
+	if !reflect.DeepEqual(hooked, []string{"known"}) {
+		t.Errorf("Hooks should only run on partial syncs: %v", hooked)
+	}
+
+	if pageToken, _ := store.PageToken("known"); pageToken != "page token go brrr" {
+		t.Errorf("Wrong pageToken for the known drive: %s", pageToken)
+	}
+
+	if pageToken, _ := store.PageToken("new"); pageToken != "100" {
+		t.Errorf("Wrong pageToken for the new drive: %s", pageToken)
+	}
+}
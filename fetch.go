@@ -1,26 +1,130 @@
 package bernard
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	ds "github.com/m-rots/bernard/datastore"
 )
 
+// itemFields are the fields of a file requested from Google Drive.
+const itemFields = "id,name,mimeType,parents,trashed,size,md5Checksum,sha1Checksum,sha256Checksum," +
+	"createdTime,modifiedTime,version,fileExtension,originalFilename,headRevisionId," +
+	"shortcutDetails(targetId,targetMimeType)"
+
+// knownFields are the fields of a file which are decoded into the driveItem struct.
+// All other fields end up in the Extra map.
+var knownFields = func() map[string]bool {
+	known := map[string]bool{"driveId": true}
+	for _, field := range splitFields(itemFields) {
+		known[fieldName(field)] = true
+	}
+
+	return known
+}()
+
+// splitFields splits a field selector into its top-level fields,
+// such as `id` and `shortcutDetails(targetId,targetMimeType)`.
+func splitFields(selector string) (fields []string) {
+	var depth, start int
+
+	for i := 0; i <= len(selector); i++ {
+		switch {
+		case i < len(selector) && selector[i] == '(':
+			depth++
+		case i < len(selector) && selector[i] == ')':
+			depth--
+		case i == len(selector) || (selector[i] == ',' && depth == 0):
+			if field := strings.TrimSpace(selector[start:i]); field != "" {
+				fields = append(fields, field)
+			}
+
+			start = i + 1
+		}
+	}
+
+	return fields
+}
+
+// fieldName returns the name of the property a top-level field selects.
+func fieldName(field string) string {
+	if i := strings.IndexAny(field, "(/"); i != -1 {
+		return field[:i]
+	}
+
+	return field
+}
+
+// shortcutMimeType is the mimeType of a shortcut to another file or folder.
+const shortcutMimeType = "application/vnd.google-apps.shortcut"
+
+type shortcutDetails struct {
+	TargetID       string `json:"targetId"`
+	TargetMimeType string
+}
+
 type driveItem struct {
-	ID          string
-	Name        string
-	MimeType    string
-	Parents     []string
-	Size        uint64 `json:"size,string"`
-	MD5Checksum string
-	Trashed     bool
-	DriveID     string
+	ID               string
+	Name             string
+	MimeType         string
+	Parents          []string
+	Size             uint64 `json:"size,string"`
+	MD5Checksum      string
+	SHA1Checksum     string `json:"sha1Checksum"`
+	SHA256Checksum   string `json:"sha256Checksum"`
+	Trashed          bool
+	DriveID          string
+	CreatedTime      time.Time
+	ModifiedTime     time.Time
+	Version          int64 `json:"version,string"`
+	FileExtension    string
+	OriginalFilename string
+	HeadRevisionID   string `json:"headRevisionId"`
+	ShortcutDetails  *shortcutDetails
+
+	// Extra holds the compacted JSON of the fields requested with WithFields.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes the known fields of a file,
+// and keeps the raw JSON of all other fields in Extra.
+func (item *driveItem) UnmarshalJSON(data []byte) error {
+	type plain driveItem
+	if err := json.Unmarshal(data, (*plain)(item)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	for key, value := range fields {
+		if knownFields[key] {
+			continue
+		}
+
+		// Compact the value so it can be compared with the stored value.
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, value); err != nil {
+			return err
+		}
+
+		if item.Extra == nil {
+			item.Extra = make(map[string]json.RawMessage)
+		}
+
+		item.Extra[key] = buf.Bytes()
+	}
+
+	return nil
 }
 
 type sharedDrive struct {
@@ -61,7 +165,9 @@ type fetcher struct {
 	auth    Authenticator
 	baseURL string
 	client  *http.Client
-	sleep   func(time.Duration)
+	sleep   func(context.Context, time.Duration) error
+	retry   RetryPolicy
+	fields  string
 
 	preHook    func()
 	decodeJSON jsonDecoder
@@ -74,26 +180,41 @@ func decodeJSON(r io.Reader, v interface{}) error {
 	return json.NewDecoder(r).Decode(v)
 }
 
-func (fetch *fetcher) withAuth(req *http.Request) (res *http.Response, err error) {
-	var retriedAttempts int
+// sleepContext pauses the current goroutine for at least the duration d,
+// unless the context is cancelled before the duration has passed.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
 
-	// handle exponential backoff
-	handleBackoff := func() {
-		var waitDuration time.Duration
+func (fetch *fetcher) withAuth(req *http.Request) (res *http.Response, err error) {
+	ctx := req.Context()
+	start := time.Now()
 
-		exponentialBackoff := math.Exp2(float64(retriedAttempts))
-		if exponentialBackoff <= 32 {
-			waitDuration = time.Duration(exponentialBackoff) * time.Second
-		} else {
-			waitDuration = time.Duration(32) * time.Second
-		}
+	// The number of attempts which count towards the MaxAttempts of the retry policy,
+	// and the number of backoffs which determine the next backoff interval.
+	attempts, backoffs := 0, 0
 
-		fetch.sleep(waitDuration)
-		retriedAttempts++
-	}
+	// The access tokens which have been rate-limited since the last backoff.
+	limited := make(map[string]bool)
 
 	// for loop to retry if necessary
-	for {
+	for attempt := 0; ; attempt++ {
+		// The body of a retried request must be read again.
+		if attempt > 0 && req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		// preHook if anyone wants to apply rate-limiting.
 		if fetch.preHook != nil {
 			fetch.preHook()
@@ -104,13 +225,19 @@ func (fetch *fetcher) withAuth(req *http.Request) (res *http.Response, err error
 			return nil, err
 		}
 
-		req.Header.Add("Authorization", "Bearer "+token)
+		// A retried request may use the access token of another account.
+		req.Header.Set("Authorization", "Bearer "+token)
 		res, err = fetch.client.Do(req)
 		if err != nil {
+			// A cancelled request is not a network error.
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
 			return nil, ErrNetwork
 		}
 
-		if res.StatusCode == 200 {
+		if res.StatusCode >= 200 && res.StatusCode < 300 {
 			return res, nil
 		}
 
@@ -118,24 +245,47 @@ func (fetch *fetcher) withAuth(req *http.Request) (res *http.Response, err error
 		fetch.decodeJSON(res.Body, response)
 		res.Body.Close()
 
-		switch res.StatusCode {
-		case 429, 500, 502, 503, 504:
-			handleBackoff()
-			continue
-		case 401:
-			return nil, ErrInvalidCredentials
-		case 403:
-			driveErrors := response.Error.Errors
-			if len(driveErrors) == 0 {
-				return nil, fmt.Errorf("%v: %w", response.Error.Message, ErrNetwork)
+		var reason string
+		if len(response.Error.Errors) > 0 {
+			reason = response.Error.Errors[0].Reason
+		}
+
+		if fetch.retry.retryable(res.StatusCode, reason) {
+			// Switch to another account before backing off,
+			// until every account has been rate-limited once.
+			rotate := false
+			if res.StatusCode == 403 && isRateLimit(reason) {
+				if auth, ok := fetch.auth.(RateLimitedAuthenticator); ok {
+					rotate = auth.RateLimited(token) && !limited[token]
+					limited[token] = true
+				}
+			}
+
+			var wait time.Duration
+			if !rotate {
+				wait = fetch.retry.backoff(backoffs, res)
+				backoffs++
+				limited = make(map[string]bool)
+			}
+
+			attempts++
+			if fetch.retry.exhausted(attempts, time.Since(start)+wait) {
+				return nil, fmt.Errorf("%v: %w", response.Error.Message, ErrRetriesExhausted)
 			}
-			switch response.Error.Errors[0].Reason {
-			case "userRateLimitExceeded", "rateLimitExceeded":
-				handleBackoff()
+
+			if rotate {
 				continue
-			default:
-				return nil, fmt.Errorf("%v: %w", response.Error.Message, ErrNetwork)
 			}
+
+			if err := fetch.sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		switch res.StatusCode {
+		case 401:
+			return nil, ErrInvalidCredentials
 		case 404:
 			return nil, fmt.Errorf("%v: %w", response.Error.Message, ErrNotFound)
 		default:
@@ -144,8 +294,8 @@ func (fetch *fetcher) withAuth(req *http.Request) (res *http.Response, err error
 	}
 }
 
-func (fetch *fetcher) pageToken(driveID string) (string, error) {
-	req, _ := http.NewRequest("GET", fetch.baseURL+"/changes/startPageToken", nil)
+func (fetch *fetcher) pageToken(ctx context.Context, driveID string) (string, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", fetch.baseURL+"/changes/startPageToken", nil)
 
 	q := url.Values{}
 	q.Add("driveId", driveID)
@@ -168,8 +318,8 @@ func (fetch *fetcher) pageToken(driveID string) (string, error) {
 	return response.StartPageToken, nil
 }
 
-func (fetch *fetcher) drive(driveID string) (string, error) {
-	req, _ := http.NewRequest("GET", fetch.baseURL+"/drives/"+driveID, nil)
+func (fetch *fetcher) drive(ctx context.Context, driveID string) (string, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", fetch.baseURL+"/drives/"+driveID, nil)
 
 	q := url.Values{}
 	q.Add("fields", "name")
@@ -191,21 +341,208 @@ func (fetch *fetcher) drive(driveID string) (string, error) {
 	return response.Name, nil
 }
 
-func (fetch *fetcher) allContent(driveID string) ([]ds.Folder, []ds.File, error) {
+func (fetch *fetcher) drives(ctx context.Context) ([]ds.Drive, error) {
+	var drives []ds.Drive
+	var pageToken string
+
+	for {
+		req, _ := http.NewRequestWithContext(ctx, "GET", fetch.baseURL+"/drives", nil)
+
+		q := url.Values{}
+		q.Add("pageSize", "100")
+		q.Add("fields", "nextPageToken,drives(id,name)")
+		if pageToken != "" {
+			q.Add("pageToken", pageToken)
+		}
+
+		req.URL.RawQuery = q.Encode()
+
+		res, err := fetch.withAuth(req)
+		if err != nil {
+			return nil, err
+		}
+
+		type Response struct {
+			Drives        []sharedDrive
+			NextPageToken string
+		}
+
+		response := new(Response)
+		fetch.decodeJSON(res.Body, response)
+		res.Body.Close()
+
+		for _, drive := range response.Drives {
+			drives = append(drives, ds.Drive{ID: drive.ID, Name: drive.Name})
+		}
+
+		pageToken = response.NextPageToken
+
+		if pageToken == "" {
+			break
+		}
+	}
+
+	return drives, nil
+}
+
+// channelRequest is the body of a changes.watch and channels.stop request.
+type channelRequest struct {
+	ID         string `json:"id"`
+	ResourceID string `json:"resourceId,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Address    string `json:"address,omitempty"`
+	Token      string `json:"token,omitempty"`
+	Expiration int64  `json:"expiration,string,omitempty"`
+}
+
+// watchChanges opens a notification channel for the changes of a Shared Drive
+// following the provided pageToken.
+func (fetch *fetcher) watchChanges(ctx context.Context, driveID string, pageToken string, channel *Channel) error {
+	body, _ := json.Marshal(channelRequest{
+		ID:         channel.ID,
+		Type:       "web_hook",
+		Address:    channel.Address,
+		Token:      channel.Token,
+		Expiration: channel.Expiration.UnixNano() / int64(time.Millisecond),
+	})
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", fetch.baseURL+"/changes/watch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	q := url.Values{}
+	q.Add("driveId", driveID)
+	q.Add("pageToken", pageToken)
+	q.Add("includeItemsFromAllDrives", "true")
+	q.Add("supportsAllDrives", "true")
+	req.URL.RawQuery = q.Encode()
+
+	res, err := fetch.withAuth(req)
+	if err != nil {
+		return err
+	}
+
+	response := new(channelRequest)
+	fetch.decodeJSON(res.Body, response)
+	res.Body.Close()
+
+	channel.ResourceID = response.ResourceID
+	if response.Expiration != 0 {
+		channel.Expiration = time.Unix(0, response.Expiration*int64(time.Millisecond))
+	}
+
+	return nil
+}
+
+// stopChannel stops the notifications of the provided channel.
+func (fetch *fetcher) stopChannel(ctx context.Context, channel *Channel) error {
+	body, _ := json.Marshal(channelRequest{
+		ID:         channel.ID,
+		ResourceID: channel.ResourceID,
+	})
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", fetch.baseURL+"/channels/stop", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := fetch.withAuth(req)
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+	return nil
+}
+
+func (fetch *fetcher) allContent(ctx context.Context, driveID string) ([]ds.Folder, []ds.File, error) {
+	var files []ds.File
+	var folders []ds.Folder
+
+	err := fetch.contentPages(ctx, driveID, "", func(newFolders []ds.Folder, newFiles []ds.File, _ string) error {
+		folders = append(folders, newFolders...)
+		files = append(files, newFiles...)
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	orderedFolders := ds.OrderFoldersOnHierarchy(folders)
+	return orderedFolders, files, nil
+}
+
+// contentPages fetches all the content of a Shared Drive and calls fn for every page.
+// The provided pageToken is the first page to fetch, or empty to start at the beginning.
+//
+// The folders within a page are ordered on hierarchy. However, folders and files
+// can reference parents which are only part of a later page.
+func (fetch *fetcher) contentPages(ctx context.Context, driveID string, pageToken string, fn func(folders []ds.Folder, files []ds.File, nextPageToken string) error) error {
+	for {
+		req, _ := http.NewRequestWithContext(ctx, "GET", fetch.baseURL+"/files", nil)
+
+		q := url.Values{}
+		q.Add("corpora", "drive")
+		q.Add("driveId", driveID)
+		q.Add("pageSize", "1000")
+		q.Add("includeItemsFromAllDrives", "true")
+		q.Add("supportsAllDrives", "true")
+		q.Add("fields", "nextPageToken,files("+fetch.fields+")")
+		if pageToken != "" {
+			q.Add("pageToken", pageToken)
+		}
+
+		req.URL.RawQuery = q.Encode()
+
+		res, err := fetch.withAuth(req)
+		if err != nil {
+			return err
+		}
+
+		type Response struct {
+			Files         []driveItem
+			NextPageToken string
+		}
+
+		response := new(Response)
+		fetch.decodeJSON(res.Body, response)
+		res.Body.Close()
+
+		folders, files, err := convert(response.Files)
+		if err != nil {
+			return err
+		}
+
+		err = fn(ds.OrderFoldersOnHierarchy(folders), files, response.NextPageToken)
+		if err != nil {
+			return err
+		}
+
+		pageToken = response.NextPageToken
+
+		if pageToken == "" {
+			break
+		}
+	}
+
+	return nil
+}
+
+// folderContent fetches the direct children of the folder with the provided ID.
+func (fetch *fetcher) folderContent(ctx context.Context, driveID string, folderID string) ([]ds.Folder, []ds.File, error) {
 	var files []ds.File
 	var folders []ds.Folder
 	var pageToken string
 
 	for {
-		req, _ := http.NewRequest("GET", fetch.baseURL+"/files", nil)
+		req, _ := http.NewRequestWithContext(ctx, "GET", fetch.baseURL+"/files", nil)
 
 		q := url.Values{}
 		q.Add("corpora", "drive")
 		q.Add("driveId", driveID)
+		q.Add("q", fmt.Sprintf("'%s' in parents", folderID))
 		q.Add("pageSize", "1000")
 		q.Add("includeItemsFromAllDrives", "true")
 		q.Add("supportsAllDrives", "true")
-		q.Add("fields", "nextPageToken,files(id,name,mimeType,parents,md5Checksum,size,trashed)")
+		q.Add("fields", "nextPageToken,files("+fetch.fields+")")
 		if pageToken != "" {
 			q.Add("pageToken", pageToken)
 		}
@@ -223,10 +560,19 @@ func (fetch *fetcher) allContent(driveID string) ([]ds.Folder, []ds.File, error)
 		}
 
 		response := new(Response)
-		fetch.decodeJSON(res.Body, response)
+		err = fetch.decodeJSON(res.Body, response)
 		res.Body.Close()
 
-		newFolders, newFiles := convert(response.Files)
+		// An invalid listing would silently leave out the content of the folder.
+		if err != nil {
+			return nil, nil, fmt.Errorf("%v: %w", err, ErrNetwork)
+		}
+
+		newFolders, newFiles, err := convert(response.Files)
+		if err != nil {
+			return nil, nil, err
+		}
+
 		folders = append(folders, newFolders...)
 		files = append(files, newFiles...)
 
@@ -237,11 +583,121 @@ func (fetch *fetcher) allContent(driveID string) ([]ds.Folder, []ds.File, error)
 		}
 	}
 
-	orderedFolders := ds.OrderFoldersOnHierarchy(folders)
-	return orderedFolders, files, nil
+	return folders, files, nil
 }
 
-func (fetch *fetcher) changedContent(driveID string, pageToken string) (*changedContent, error) {
+// parallelContent fetches all the content of a Shared Drive by listing the children
+// of every folder, with up to the provided number of folders being listed concurrently.
+//
+// The output is independent of the order in which the listings complete,
+// as the folders and files are assembled by walking the folder tree breadth-first.
+// Therefore, the folders are ordered on hierarchy.
+//
+// Items with additional parents show up in the listing of every parent,
+// so every folder is only listed once and every item is only returned once.
+func (fetch *fetcher) parallelContent(ctx context.Context, driveID string, workers int) ([]ds.Folder, []ds.File, error) {
+	type listing struct {
+		folderID string
+		folders  []ds.Folder
+		files    []ds.File
+		err      error
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan string)
+	results := make(chan listing)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for folderID := range jobs {
+				folders, files, err := fetch.folderContent(ctx, driveID, folderID)
+				results <- listing{folderID, folders, files, err}
+			}
+		}()
+	}
+
+	listings := make(map[string]listing)
+	listed := map[string]bool{driveID: true}
+	pending := []string{driveID}
+	var inFlight int
+	var err error
+
+	for err == nil && (len(pending) > 0 || inFlight > 0) {
+		// Only offer a job when a folder is waiting to be listed.
+		var send chan string
+		var next string
+
+		if len(pending) > 0 {
+			send = jobs
+			next = pending[0]
+		}
+
+		select {
+		case send <- next:
+			pending = pending[1:]
+			inFlight++
+		case result := <-results:
+			inFlight--
+
+			if result.err != nil {
+				err = result.err
+				break
+			}
+
+			listings[result.folderID] = result
+			for _, folder := range result.folders {
+				if !listed[folder.ID] {
+					listed[folder.ID] = true
+					pending = append(pending, folder.ID)
+				}
+			}
+		}
+	}
+
+	// Stop the workers and wait for the in-flight listings to return.
+	cancel()
+	close(jobs)
+
+	for ; inFlight > 0; inFlight-- {
+		<-results
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var folders []ds.Folder
+	var files []ds.File
+
+	seen := map[string]bool{driveID: true}
+
+	queue := []string{driveID}
+	for len(queue) > 0 {
+		result := listings[queue[0]]
+		queue = queue[1:]
+
+		for _, folder := range result.folders {
+			if !seen[folder.ID] {
+				seen[folder.ID] = true
+				folders = append(folders, folder)
+				queue = append(queue, folder.ID)
+			}
+		}
+
+		for _, file := range result.files {
+			if !seen[file.ID] {
+				seen[file.ID] = true
+				files = append(files, file)
+			}
+		}
+	}
+
+	return folders, files, nil
+}
+
+func (fetch *fetcher) changedContent(ctx context.Context, driveID string, pageToken string) (*changedContent, error) {
 	var files []ds.File
 	var folders []ds.Folder
 	var removedIDs []string
@@ -249,7 +705,7 @@ func (fetch *fetcher) changedContent(driveID string, pageToken string) (*changed
 	drive := ds.Drive{ID: driveID}
 
 	for {
-		req, _ := http.NewRequest("GET", fetch.baseURL+"/changes", nil)
+		req, _ := http.NewRequestWithContext(ctx, "GET", fetch.baseURL+"/changes", nil)
 
 		q := url.Values{}
 		q.Add("driveId", driveID)
@@ -257,7 +713,7 @@ func (fetch *fetcher) changedContent(driveID string, pageToken string) (*changed
 		q.Add("pageToken", pageToken)
 		q.Add("includeItemsFromAllDrives", "true")
 		q.Add("supportsAllDrives", "true")
-		q.Add("fields", "nextPageToken,newStartPageToken,changes(driveId,fileId,removed,drive(id,name),file(id,driveId,name,mimeType,parents,md5Checksum,size,trashed))")
+		q.Add("fields", "nextPageToken,newStartPageToken,changes(driveId,fileId,removed,drive(id,name),file(driveId,"+fetch.fields+"))")
 		req.URL.RawQuery = q.Encode()
 
 		res, err := fetch.withAuth(req)
@@ -294,7 +750,11 @@ func (fetch *fetcher) changedContent(driveID string, pageToken string) (*changed
 			}
 		}
 
-		changedFolders, changedFiles := convert(changedItems)
+		changedFolders, changedFiles, err := convert(changedItems)
+		if err != nil {
+			return nil, err
+		}
+
 		folders = append(folders, changedFolders...)
 		files = append(files, changedFiles...)
 
@@ -318,14 +778,30 @@ func (fetch *fetcher) changedContent(driveID string, pageToken string) (*changed
 	return output, nil
 }
 
-func convert(content []driveItem) (folders []ds.Folder, files []ds.File) {
+// convert splits the items into folders and files.
+//
+// The first parent of an item is its primary parent, all other parents are additional parents.
+// A ParentlessError is returned when an item does not have any parents.
+func convert(content []driveItem) (folders []ds.Folder, files []ds.File, err error) {
 	for _, item := range content {
+		if len(item.Parents) == 0 {
+			return nil, nil, &ParentlessError{ID: item.ID}
+		}
+
+		var additionalParents []string
+		if len(item.Parents) > 1 {
+			additionalParents = item.Parents[1:]
+		}
+
 		if item.MimeType == "application/vnd.google-apps.folder" {
 			folder := ds.Folder{
 				ID:      item.ID,
 				Name:    item.Name,
 				Parent:  item.Parents[0],
 				Trashed: item.Trashed,
+
+				AdditionalParents: additionalParents,
+				Extra:             item.Extra,
 			}
 
 			folders = append(folders, folder)
@@ -335,13 +811,34 @@ func convert(content []driveItem) (folders []ds.Folder, files []ds.File) {
 				Name:    item.Name,
 				Parent:  item.Parents[0],
 				Trashed: item.Trashed,
-				MD5:     item.MD5Checksum,
 				Size:    item.Size,
+				MD5:     item.MD5Checksum,
+				SHA1:    item.SHA1Checksum,
+				SHA256:  item.SHA256Checksum,
+
+				AdditionalParents: additionalParents,
+
+				MimeType:         item.MimeType,
+				CreatedTime:      item.CreatedTime,
+				ModifiedTime:     item.ModifiedTime,
+				Version:          item.Version,
+				FileExtension:    item.FileExtension,
+				OriginalFilename: item.OriginalFilename,
+				HeadRevisionID:   item.HeadRevisionID,
+
+				Extra: item.Extra,
+			}
+
+			if item.MimeType == shortcutMimeType && item.ShortcutDetails != nil {
+				file.Shortcut = &ds.Shortcut{
+					TargetID:       item.ShortcutDetails.TargetID,
+					TargetMimeType: item.ShortcutDetails.TargetMimeType,
+				}
 			}
 
 			files = append(files, file)
 		}
 	}
 
-	return folders, files
+	return folders, files, nil
 }
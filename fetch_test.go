@@ -1,13 +1,18 @@
 package bernard
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -30,9 +35,10 @@ type mockSleep struct {
 	calledWith []time.Duration
 }
 
-func (sleep *mockSleep) Sleep(d time.Duration) {
+func (sleep *mockSleep) Sleep(ctx context.Context, d time.Duration) error {
 	sleep.called++
 	sleep.calledWith = append(sleep.calledWith, d)
+	return ctx.Err()
 }
 
 func setupTest(handler http.HandlerFunc) (*fetcher, *httptest.Server, *mockSleep) {
@@ -45,6 +51,7 @@ func setupTest(handler http.HandlerFunc) (*fetcher, *httptest.Server, *mockSleep
 		decodeJSON: decodeJSON,
 		baseURL:    server.URL,
 		sleep:      sleep.Sleep,
+		fields:     itemFields,
 	}
 
 	return fetch, server, sleep
@@ -88,6 +95,196 @@ func TestExponentialBackoff(t *testing.T) {
 	}
 }
 
+func TestBackoffCancelled(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}
+
+	fetch, server, sleep := setupTest(handler)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// cancel the context during the first backoff
+	fetch.sleep = func(_ context.Context, d time.Duration) error {
+		cancel()
+		return sleep.Sleep(ctx, d)
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", fetch.baseURL, nil)
+	_, err := fetch.withAuth(req)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if sleep.called != 1 {
+		t.Errorf("Retrying after cancellation, sleep called %d times", sleep.called)
+	}
+}
+
+type mockPool struct {
+	tokens      []string
+	current     int
+	rateLimited []string
+
+	// cycle keeps switching to the next token, as if the cooldown of every token has ended.
+	cycle bool
+}
+
+func (pool *mockPool) AccessToken() (string, int64, error) {
+	return pool.tokens[pool.current], 0, nil
+}
+
+// RateLimited switches to the next token, until every token has been rate-limited.
+func (pool *mockPool) RateLimited(token string) bool {
+	pool.rateLimited = append(pool.rateLimited, token)
+	if pool.cycle {
+		pool.current = (pool.current + 1) % len(pool.tokens)
+		return true
+	}
+
+	if pool.current == len(pool.tokens)-1 {
+		return false
+	}
+
+	pool.current++
+	return true
+}
+
+func TestRateLimitedAuthenticator(t *testing.T) {
+	rateLimitExceeded, err := ioutil.ReadFile("testdata/errors/403/userRateLimitExceeded.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		tokens      []string
+		cycle       bool
+		limited     int
+		rateLimited []string
+		used        []string
+		sleep       int
+	}{
+		{
+			name:        "switch accounts",
+			tokens:      []string{"a", "b", "c"},
+			limited:     2,
+			rateLimited: []string{"a", "b"},
+			used:        []string{"a", "b", "c"},
+		},
+		{
+			name:        "back off when all accounts are rate-limited",
+			tokens:      []string{"a", "b"},
+			limited:     3,
+			rateLimited: []string{"a", "b", "b"},
+			used:        []string{"a", "b", "b", "b"},
+			sleep:       2,
+		},
+		{
+			name:        "back off once every account has been tried",
+			tokens:      []string{"a", "b"},
+			cycle:       true,
+			limited:     4,
+			rateLimited: []string{"a", "b", "a", "b"},
+			used:        []string{"a", "b", "a", "b", "a"},
+			sleep:       1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var used []string
+
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				values := r.Header.Values("Authorization")
+				if len(values) != 1 {
+					t.Errorf("Expected a single Authorization header, got: %v", values)
+				}
+
+				used = append(used, strings.TrimPrefix(values[0], "Bearer "))
+				if len(used) > tc.limited {
+					w.WriteHeader(200)
+					return
+				}
+
+				w.WriteHeader(403)
+				w.Write(rateLimitExceeded)
+			}
+
+			fetch, server, sleep := setupTest(handler)
+			defer server.Close()
+
+			pool := &mockPool{tokens: tc.tokens, cycle: tc.cycle}
+			fetch.auth = pool
+
+			req, _ := http.NewRequest("GET", fetch.baseURL, nil)
+			if _, err := fetch.withAuth(req); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(pool.rateLimited, tc.rateLimited) {
+				t.Errorf("Unexpected rate-limited tokens: %v", pool.rateLimited)
+			}
+
+			if !reflect.DeepEqual(used, tc.used) {
+				t.Errorf("Unexpected tokens used: %v", used)
+			}
+
+			if sleep.called != tc.sleep {
+				t.Errorf("Expected %d backoffs, got: %d", tc.sleep, sleep.called)
+			}
+		})
+	}
+}
+
+func TestRateLimitedAttempts(t *testing.T) {
+	rateLimitExceeded, err := ioutil.ReadFile("testdata/errors/403/userRateLimitExceeded.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var called int
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		called++
+		w.WriteHeader(403)
+		w.Write(rateLimitExceeded)
+	}
+
+	fetch, server, sleep := setupTest(handler)
+	defer server.Close()
+
+	// Switching accounts counts towards the maximum number of attempts.
+	fetch.auth = &mockPool{tokens: []string{"a", "b", "c"}, cycle: true}
+	fetch.retry = RetryPolicy{MaxAttempts: 5}
+
+	req, _ := http.NewRequest("GET", fetch.baseURL, nil)
+	if _, err := fetch.withAuth(req); !errors.Is(err, ErrRetriesExhausted) {
+		t.Fatalf("Expected ErrRetriesExhausted, got: %v", err)
+	}
+
+	if called != 5 || sleep.called != 1 {
+		t.Errorf("Expected 5 requests and 1 backoff, got: %d and %d", called, sleep.called)
+	}
+}
+
+func TestRequestCancelled(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request should not reach the server")
+	}
+
+	fetch, server, _ := setupTest(handler)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := fetch.pageToken(ctx, driveID)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
 func TestDrive(t *testing.T) {
 	type Expected struct {
 		name string
@@ -118,7 +315,7 @@ func TestDrive(t *testing.T) {
 			fetch, server, _ := setupTest(handler)
 			defer server.Close()
 
-			name, err := fetch.drive(driveID)
+			name, err := fetch.drive(context.Background(), driveID)
 			if err != nil {
 				t.Errorf("unexpected error: %s", err.Error())
 				return
@@ -161,7 +358,7 @@ func TestPageToken(t *testing.T) {
 			fetch, server, _ := setupTest(handler)
 			defer server.Close()
 
-			pageToken, err := fetch.pageToken(driveID)
+			pageToken, err := fetch.pageToken(context.Background(), driveID)
 			if err != nil {
 				t.Errorf("unexpected error: %s", err.Error())
 				return
@@ -204,10 +401,20 @@ func TestAllContent(t *testing.T) {
 				{
 					ID:      "Z",
 					MD5:     "ZZZ",
+					SHA1:    "ZZZ1",
+					SHA256:  "ZZZ256",
 					Name:    "FILE Z",
 					Parent:  "A",
 					Size:    10,
 					Trashed: false,
+
+					MimeType:         "image/jpeg",
+					CreatedTime:      time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC),
+					ModifiedTime:     time.Date(2020, 3, 2, 12, 30, 15, 123000000, time.UTC),
+					Version:          4,
+					FileExtension:    "jpg",
+					OriginalFilename: "z.jpg",
+					HeadRevisionID:   "revision Z",
 				},
 				{
 					ID:      "Y",
@@ -216,6 +423,8 @@ func TestAllContent(t *testing.T) {
 					Parent:  "B",
 					Size:    100,
 					Trashed: true,
+
+					MimeType: "image/png",
 				},
 			},
 		},
@@ -274,7 +483,7 @@ func TestAllContent(t *testing.T) {
 			fetch, server, _ := setupTest(handler)
 			defer server.Close()
 
-			folders, files, err := fetch.allContent(driveID)
+			folders, files, err := fetch.allContent(context.Background(), driveID)
 			if err != nil {
 				t.Errorf("AllContent returned an error: %s", err.Error())
 				return
@@ -297,6 +506,252 @@ func TestAllContent(t *testing.T) {
 	}
 }
 
+func TestContentPages(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fixturePath := "testdata/all-content/pageToken.json"
+		if pageToken := r.URL.Query().Get("pageToken"); pageToken != "" {
+			fixturePath = pageToken
+		}
+
+		http.ServeFile(w, r, fixturePath)
+	}
+
+	fetch, server, _ := setupTest(handler)
+	defer server.Close()
+
+	var pages [][]ds.File
+
+	var nextPageTokens []string
+
+	err := fetch.contentPages(context.Background(), driveID, "", func(folders []ds.Folder, files []ds.File, nextPageToken string) error {
+		pages = append(pages, files)
+		nextPageTokens = append(nextPageTokens, nextPageToken)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("ContentPages returned an error: %s", err.Error())
+	}
+
+	expected := [][]ds.File{
+		{{ID: "Z", Parent: "testDrive"}},
+		{{ID: "X", Parent: "testDrive"}},
+	}
+
+	if !reflect.DeepEqual(pages, expected) {
+		t.Log(pages)
+		t.Log(expected)
+		t.Error("Pages do not match the expected output")
+	}
+
+	if !reflect.DeepEqual(nextPageTokens, []string{"testdata/all-content/pageToken2.json", ""}) {
+		t.Log(nextPageTokens)
+		t.Error("Next pageTokens do not match the expected output")
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		expected := "nextPageToken,files(" + itemFields + ",imageMediaMetadata(width,height),properties)"
+		if fields := r.URL.Query().Get("fields"); fields != expected {
+			t.Errorf("Unexpected fields: %s", fields)
+		}
+
+		http.ServeFile(w, r, "testdata/all-content/extra.json")
+	}
+
+	fetch, server, _ := setupTest(handler)
+	defer server.Close()
+
+	// known and empty fields are ignored
+	bernard := &Bernard{fetch: fetch}
+	WithFields("imageMediaMetadata(width,height)", "size,shortcutDetails(targetId)", "", "properties")(bernard)
+
+	folders, files, err := fetch.allContent(context.Background(), driveID)
+	if err != nil {
+		t.Fatalf("AllContent returned an error: %s", err.Error())
+	}
+
+	expectedFolders := []ds.Folder{
+		{
+			ID:     "A",
+			Name:   "FOLDER A",
+			Parent: driveID,
+			Extra: map[string]json.RawMessage{
+				"properties": json.RawMessage(`{"owner":"media"}`),
+			},
+		},
+	}
+
+	expectedFiles := []ds.File{
+		{
+			ID:       "Z",
+			Name:     "FILE Z",
+			Parent:   "A",
+			Size:     10,
+			MimeType: "image/jpeg",
+			Extra: map[string]json.RawMessage{
+				"imageMediaMetadata": json.RawMessage(`{"width":1920,"height":1080}`),
+			},
+		},
+		{
+			ID:       "S",
+			Name:     "SHORTCUT S",
+			Parent:   "A",
+			MimeType: "application/vnd.google-apps.shortcut",
+			Shortcut: &ds.Shortcut{
+				TargetID:       "Z",
+				TargetMimeType: "image/jpeg",
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(folders, expectedFolders) {
+		t.Log(folders)
+		t.Log(expectedFolders)
+		t.Error("Folders do not match the expected output")
+	}
+
+	if !reflect.DeepEqual(files, expectedFiles) {
+		t.Log(files)
+		t.Log(expectedFiles)
+		t.Error("Files do not match the expected output")
+	}
+}
+
+// parallelHandler serves the listing of every folder from the fixture directory,
+// and counts the number of times every folder is listed.
+func parallelHandler(t *testing.T, dir string, listed map[string]int) http.HandlerFunc {
+	var mu sync.Mutex
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if pageToken := r.URL.Query().Get("pageToken"); pageToken != "" {
+			http.ServeFile(w, r, pageToken)
+			return
+		}
+
+		var folderID string
+		_, err := fmt.Sscanf(r.URL.Query().Get("q"), "'%s in parents", &folderID)
+		if err != nil {
+			t.Errorf("Invalid query: %s", r.URL.Query().Get("q"))
+		}
+
+		folderID = strings.TrimSuffix(folderID, "'")
+
+		mu.Lock()
+		listed[folderID]++
+		mu.Unlock()
+
+		http.ServeFile(w, r, dir+"/"+folderID+".json")
+	}
+}
+
+func TestParallelContent(t *testing.T) {
+	fetch, server, _ := setupTest(parallelHandler(t, "testdata/parallel-content", make(map[string]int)))
+	defer server.Close()
+
+	folders, files, err := fetch.parallelContent(context.Background(), driveID, 3)
+	if err != nil {
+		t.Fatalf("ParallelContent returned an error: %s", err.Error())
+	}
+
+	expectedFolders := []ds.Folder{
+		{ID: "A", Name: "FOLDER A", Parent: driveID},
+		{ID: "C", Name: "FOLDER C", Parent: driveID},
+		{ID: "B", Name: "FOLDER B", Parent: "A"},
+	}
+
+	if !reflect.DeepEqual(folders, expectedFolders) {
+		t.Log(folders)
+		t.Log(expectedFolders)
+		t.Error("Folders do not match the expected output")
+	}
+
+	expectedFiles := []ds.File{
+		{ID: "Z", Name: "FILE Z", Parent: driveID},
+		{ID: "Y", Name: "FILE Y", Parent: "A"},
+		{ID: "X", Name: "FILE X", Parent: "B", Trashed: true},
+	}
+
+	if !reflect.DeepEqual(files, expectedFiles) {
+		t.Log(files)
+		t.Log(expectedFiles)
+		t.Error("Files do not match the expected output")
+	}
+}
+
+func TestParallelContentParents(t *testing.T) {
+	listed := make(map[string]int)
+
+	// Folders A and C are each other's additional parent, and B has both as its parents.
+	fetch, server, _ := setupTest(parallelHandler(t, "testdata/parallel-content-parents", listed))
+	defer server.Close()
+
+	folders, files, err := fetch.parallelContent(context.Background(), driveID, 3)
+	if err != nil {
+		t.Fatalf("ParallelContent returned an error: %s", err.Error())
+	}
+
+	expectedFolders := []ds.Folder{
+		{ID: "A", Name: "FOLDER A", Parent: driveID, AdditionalParents: []string{"C"}},
+		{ID: "C", Name: "FOLDER C", Parent: driveID, AdditionalParents: []string{"A"}},
+		{ID: "B", Name: "FOLDER B", Parent: "A", AdditionalParents: []string{"C"}},
+	}
+
+	if !reflect.DeepEqual(folders, expectedFolders) {
+		t.Log(folders)
+		t.Error("Folders do not match the expected output")
+	}
+
+	expectedFiles := []ds.File{
+		{ID: "Z", Name: "FILE Z", Parent: driveID, AdditionalParents: []string{"C"}},
+		{ID: "X", Name: "FILE X", Parent: "B"},
+	}
+
+	if !reflect.DeepEqual(files, expectedFiles) {
+		t.Log(files)
+		t.Error("Files do not match the expected output")
+	}
+
+	expectedListed := map[string]int{driveID: 1, "A": 1, "B": 1, "C": 1}
+	if !reflect.DeepEqual(listed, expectedListed) {
+		t.Errorf("Every folder should be listed once, got: %v", listed)
+	}
+}
+
+func TestParallelContentInvalidJSON(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"files": [{"id": "Z", "name": "FILE Z"`))
+	}
+
+	fetch, server, _ := setupTest(handler)
+	defer server.Close()
+
+	_, _, err := fetch.parallelContent(context.Background(), driveID, 2)
+	if !errors.Is(err, ErrNetwork) {
+		t.Fatalf("Expected ErrNetwork for a truncated listing, got: %v", err)
+	}
+}
+
+func TestParallelContentError(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") == "'testDrive' in parents" {
+			http.ServeFile(w, r, "testdata/parallel-content/testDrive.json")
+			return
+		}
+
+		w.WriteHeader(404)
+	}
+
+	fetch, server, _ := setupTest(handler)
+	defer server.Close()
+
+	_, _, err := fetch.parallelContent(context.Background(), driveID, 2)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
 func TestChangedContent(t *testing.T) {
 	type test struct {
 		name     string
@@ -317,10 +772,20 @@ func TestChangedContent(t *testing.T) {
 					{
 						ID:      "B",
 						MD5:     "BBB",
+						SHA1:    "BBB1",
+						SHA256:  "BBB256",
 						Name:    "file B",
 						Parent:  "A",
 						Size:    10,
 						Trashed: true,
+
+						MimeType:         "image/jpeg",
+						CreatedTime:      time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC),
+						ModifiedTime:     time.Date(2020, 3, 2, 12, 30, 15, 123000000, time.UTC),
+						Version:          7,
+						FileExtension:    "jpeg",
+						OriginalFilename: "b.jpeg",
+						HeadRevisionID:   "revision B",
 					},
 				},
 				ChangedFolders: []ds.Folder{
@@ -431,7 +896,7 @@ func TestChangedContent(t *testing.T) {
 			fetch, server, _ := setupTest(handler)
 			defer server.Close()
 
-			diff, err := fetch.changedContent(driveID, tc.fixture)
+			diff, err := fetch.changedContent(context.Background(), driveID, tc.fixture)
 			if err != nil {
 				t.Errorf("ChangedContent returned an error: %s", err.Error())
 				return
@@ -634,6 +1099,7 @@ func TestConvert(t *testing.T) {
 		input   []driveItem
 		folders []ds.Folder
 		files   []ds.File
+		err     error
 	}
 
 	var testCases = []test{
@@ -654,7 +1120,7 @@ func TestConvert(t *testing.T) {
 					MimeType:    "image/png",
 					MD5Checksum: "MD5 B",
 					Size:        1010,
-					Parents:     []string{"A"},
+					Parents:     []string{"A", "C", "Z"},
 				},
 				{
 					ID:       "C",
@@ -679,6 +1145,8 @@ func TestConvert(t *testing.T) {
 					Name:    "FOLDER A",
 					Trashed: false,
 					Parent:  "Z",
+
+					AdditionalParents: []string{"Y"},
 				},
 				{
 					ID:      "C",
@@ -695,6 +1163,9 @@ func TestConvert(t *testing.T) {
 					MD5:     "MD5 B",
 					Size:    1010,
 					Parent:  "A",
+
+					AdditionalParents: []string{"C", "Z"},
+					MimeType:          "image/png",
 				},
 				{
 					ID:      "D",
@@ -703,14 +1174,67 @@ func TestConvert(t *testing.T) {
 					MD5:     "MD5 D",
 					Size:    101010,
 					Parent:  "C",
+
+					MimeType: "image/jpeg",
+				},
+			},
+		},
+		{
+			name: "shortcut",
+			input: []driveItem{
+				{
+					ID:       "A",
+					Name:     "SHORTCUT A",
+					MimeType: "application/vnd.google-apps.shortcut",
+					Parents:  []string{"Z"},
+					ShortcutDetails: &shortcutDetails{
+						TargetID:       "B",
+						TargetMimeType: folderMime,
+					},
+				},
+			},
+			files: []ds.File{
+				{
+					ID:       "A",
+					Name:     "SHORTCUT A",
+					Parent:   "Z",
+					MimeType: "application/vnd.google-apps.shortcut",
+					Shortcut: &ds.Shortcut{
+						TargetID:       "B",
+						TargetMimeType: folderMime,
+					},
 				},
 			},
 		},
+		{
+			name: "no parents",
+			input: []driveItem{
+				{
+					ID:       "A",
+					Name:     "FOLDER A",
+					MimeType: folderMime,
+					Parents:  []string{"Z"},
+				},
+				{
+					ID:       "B",
+					Name:     "FILE B",
+					MimeType: "image/png",
+				},
+			},
+			err: &ParentlessError{ID: "B"},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			folders, files := convert(tc.input)
+			folders, files, err := convert(tc.input)
+			if !reflect.DeepEqual(err, tc.err) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if tc.err != nil && !errors.Is(err, ds.ErrDataAnomaly) {
+				t.Errorf("A parentless item should be a data anomaly: %v", err)
+			}
 
 			if !reflect.DeepEqual(folders, tc.folders) {
 				t.Log(folders)
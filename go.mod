@@ -3,6 +3,7 @@ module github.com/m-rots/bernard
 go 1.14
 
 require (
-	github.com/m-rots/stubbs v1.0.0
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v2.0.3+incompatible
+	go.etcd.io/bbolt v1.3.5
 )
@@ -0,0 +1,503 @@
+package bernard
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A Channel is a notification channel through which Google Drive
+// pushes a notification whenever a Shared Drive has changed.
+type Channel struct {
+	// ID is the unique identifier of the channel, generated by Bernard.
+	ID string
+
+	// ResourceID identifies the watched resource and is provided by Google Drive.
+	ResourceID string
+
+	// DriveID is the ID of the watched Shared Drive.
+	DriveID string
+
+	// Address is the URL the notifications are sent to.
+	Address string
+
+	// Token is a secret generated by Bernard, which Google Drive includes in every notification.
+	Token string
+
+	// Expiration is the time at which Google Drive stops sending notifications.
+	Expiration time.Time
+}
+
+// pushReceiver holds the configuration of the PushReceiver.
+type pushReceiver struct {
+	lifetime    time.Duration
+	renewBefore time.Duration
+	retryDelay  time.Duration
+
+	hooks   []Hook
+	onError func(driveID string, err error)
+}
+
+// A PushOption can override some of the default PushReceiver values.
+type PushOption func(*pushReceiver)
+
+// PushLifetime sets the requested lifetime of every channel.
+// Google Drive may shorten the lifetime of a channel.
+//
+// The default lifetime is set at 24 hours.
+func PushLifetime(lifetime time.Duration) PushOption {
+	return func(r *pushReceiver) {
+		r.lifetime = lifetime
+	}
+}
+
+// PushRenewBefore sets how long before its expiration a channel is renewed.
+//
+// The default is set at 10 minutes.
+func PushRenewBefore(renewBefore time.Duration) PushOption {
+	return func(r *pushReceiver) {
+		r.renewBefore = renewBefore
+	}
+}
+
+// PushRetryDelay sets the time to wait before retrying a failed sync.
+// The delay doubles after every consecutive failure of the same Shared Drive,
+// up to a maximum of one hour.
+//
+// The default retry delay is set at 30 seconds.
+func PushRetryDelay(delay time.Duration) PushOption {
+	return func(r *pushReceiver) {
+		r.retryDelay = delay
+	}
+}
+
+// PushHooks provides the Hooks to run on every partial sync.
+func PushHooks(hooks ...Hook) PushOption {
+	return func(r *pushReceiver) {
+		r.hooks = hooks
+	}
+}
+
+// PushErrors provides a function which is called with every error
+// of a sync or a channel renewal.
+func PushErrors(onError func(driveID string, err error)) PushOption {
+	return func(r *pushReceiver) {
+		r.onError = onError
+	}
+}
+
+// A PushReceiver receives the push notifications of Google Drive
+// and partially syncs a Shared Drive whenever it has changed.
+//
+// The PushReceiver is an http.Handler which must be served at the address
+// provided to NewPushReceiver. Next to serving the PushReceiver,
+// Run must be called to process the notifications and renew the channels.
+type PushReceiver struct {
+	bernard *Bernard
+	address string
+	config  pushReceiver
+
+	mu       sync.Mutex
+	channels map[string]*Channel
+	pending  map[string]bool
+
+	// retries holds the time of the next renewal attempt of the channels
+	// of which the renewal has failed, keyed on the channel ID.
+	retries map[string]time.Time
+
+	// backoffs holds the next sync attempt of the pending Shared Drives
+	// of which the sync has failed, keyed on the drive ID.
+	backoffs map[string]backoff
+
+	// wake signals Run that a sync is pending or a channel is registered.
+	wake chan struct{}
+}
+
+// A backoff postpones the sync of a Shared Drive after a failed sync.
+type backoff struct {
+	at    time.Time
+	delay time.Duration
+}
+
+// NewPushReceiver creates a PushReceiver for notifications sent to the provided address.
+// The address must be a publicly reachable HTTPS URL.
+func (bernard *Bernard) NewPushReceiver(address string, opts ...PushOption) *PushReceiver {
+	config := pushReceiver{
+		lifetime:    24 * time.Hour,
+		renewBefore: 10 * time.Minute,
+		retryDelay:  30 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return &PushReceiver{
+		bernard:  bernard,
+		address:  address,
+		config:   config,
+		channels: make(map[string]*Channel),
+		pending:  make(map[string]bool),
+		retries:  make(map[string]time.Time),
+		backoffs: make(map[string]backoff),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// renewRetryDelay is the delay before the renewal of a channel is retried.
+const renewRetryDelay = time.Minute
+
+// maxSyncRetryDelay is the longest delay before a failed sync is retried.
+const maxSyncRetryDelay = time.Hour
+
+// ErrChannelExpired is reported when a channel expires before it could be renewed,
+// after which the channel is removed.
+var ErrChannelExpired = errors.New("bernard: notification channel expired")
+
+// ErrUnknownChannel occurs when a notification or a stop request
+// does not match any of the registered channels.
+var ErrUnknownChannel = errors.New("bernard: unknown notification channel")
+
+// randomString returns a random hexadecimal string of 32 characters.
+func randomString() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Register opens a notification channel for the changes of the Shared Drive.
+//
+// The Shared Drive must have been fully synchronised beforehand,
+// as the channel only notifies of changes following the pageToken of the datastore.
+func (receiver *PushReceiver) Register(ctx context.Context, driveID string) (*Channel, error) {
+	pageToken, err := receiver.bernard.storePageToken(ctx, driveID)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+
+	channel := &Channel{
+		ID:         id,
+		DriveID:    driveID,
+		Address:    receiver.address,
+		Token:      token,
+		Expiration: time.Now().Add(receiver.config.lifetime),
+	}
+
+	// The channel is known before the watch request is made,
+	// as Google Drive sends a sync notification as soon as the channel is created.
+	receiver.mu.Lock()
+	receiver.channels[channel.ID] = channel
+	receiver.mu.Unlock()
+
+	registered := *channel
+
+	err = receiver.bernard.fetch.watchChanges(ctx, driveID, pageToken, &registered)
+	if err != nil {
+		receiver.mu.Lock()
+		delete(receiver.channels, channel.ID)
+		receiver.mu.Unlock()
+
+		return nil, err
+	}
+
+	receiver.mu.Lock()
+	channel.ResourceID = registered.ResourceID
+	channel.Expiration = registered.Expiration
+	receiver.mu.Unlock()
+
+	receiver.signal()
+	return &registered, nil
+}
+
+// Stop stops the notifications of the channel with the provided ID.
+func (receiver *PushReceiver) Stop(ctx context.Context, channelID string) error {
+	receiver.mu.Lock()
+	channel, ok := receiver.channels[channelID]
+	delete(receiver.channels, channelID)
+	delete(receiver.retries, channelID)
+	receiver.mu.Unlock()
+
+	if !ok {
+		return ErrUnknownChannel
+	}
+
+	return receiver.bernard.fetch.stopChannel(ctx, channel)
+}
+
+// Channels returns a copy of all registered channels.
+func (receiver *PushReceiver) Channels() []Channel {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	channels := make([]Channel, 0, len(receiver.channels))
+	for _, channel := range receiver.channels {
+		channels = append(channels, *channel)
+	}
+
+	return channels
+}
+
+// ServeHTTP validates an incoming notification of Google Drive
+// and schedules a partial sync of the changed Shared Drive.
+//
+// Notifications with an unknown channel ID are answered with a 404 status code,
+// notifications with an invalid token or resource ID with a 403 status code.
+func (receiver *PushReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var channel Channel
+
+	receiver.mu.Lock()
+	registered, ok := receiver.channels[r.Header.Get("X-Goog-Channel-ID")]
+	if ok {
+		channel = *registered
+	}
+	receiver.mu.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	token := r.Header.Get("X-Goog-Channel-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(channel.Token)) != 1 {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	// The resource ID is only known once the watch request has returned,
+	// which can be after the sync notification has been received.
+	if channel.ResourceID != "" && r.Header.Get("X-Goog-Resource-ID") != channel.ResourceID {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	// The sync message only confirms the creation of the channel.
+	if r.Header.Get("X-Goog-Resource-State") != "sync" {
+		receiver.mu.Lock()
+		receiver.pending[channel.DriveID] = true
+		receiver.mu.Unlock()
+
+		receiver.signal()
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// signal wakes Run without blocking.
+func (receiver *PushReceiver) signal() {
+	select {
+	case receiver.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run partially syncs the Shared Drives of which a notification has been received
+// and renews the channels before they expire, until the context is cancelled.
+//
+// Multiple notifications of the same Shared Drive received during a sync
+// result in a single follow-up sync. A failed sync is retried after the delay
+// set with PushRetryDelay. Run always returns the error of the context.
+func (receiver *PushReceiver) Run(ctx context.Context) error {
+	for {
+		timer := time.NewTimer(receiver.nextWake())
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-receiver.wake:
+			timer.Stop()
+			receiver.syncPending(ctx)
+		case <-timer.C:
+			receiver.renew(ctx)
+			receiver.syncPending(ctx)
+		}
+	}
+}
+
+// nextWake returns the duration until the next channel must be renewed
+// or the next failed sync must be retried.
+func (receiver *PushReceiver) nextWake() time.Duration {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	// Wake up at least once an hour when no channels exist.
+	next := time.Hour
+	for _, channel := range receiver.channels {
+		if until := time.Until(receiver.renewAt(channel)); until < next {
+			next = until
+		}
+	}
+
+	for driveID := range receiver.pending {
+		if b, ok := receiver.backoffs[driveID]; ok {
+			if until := time.Until(b.at); until < next {
+				next = until
+			}
+		}
+	}
+
+	if next < 0 {
+		return 0
+	}
+
+	return next
+}
+
+// renewAt returns the time at which the channel must be renewed,
+// which is postponed after a failed renewal. The caller must hold the lock.
+func (receiver *PushReceiver) renewAt(channel *Channel) time.Time {
+	if retry, ok := receiver.retries[channel.ID]; ok {
+		return retry
+	}
+
+	return channel.Expiration.Add(-receiver.config.renewBefore)
+}
+
+// syncPending partially syncs every Shared Drive with a pending notification,
+// except for those of which the retry of a failed sync is not due yet.
+//
+// A Shared Drive of which the sync fails is kept pending and retried after a backoff.
+func (receiver *PushReceiver) syncPending(ctx context.Context) {
+	var due []string
+
+	receiver.mu.Lock()
+	now := time.Now()
+	for driveID := range receiver.pending {
+		if b, ok := receiver.backoffs[driveID]; ok && b.at.After(now) {
+			continue
+		}
+
+		due = append(due, driveID)
+		delete(receiver.pending, driveID)
+	}
+	receiver.mu.Unlock()
+
+	for _, driveID := range due {
+		err := receiver.bernard.PartialSyncContext(ctx, driveID, receiver.config.hooks...)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			receiver.report(driveID, err)
+			receiver.retrySync(driveID)
+			continue
+		}
+
+		receiver.mu.Lock()
+		delete(receiver.backoffs, driveID)
+		receiver.mu.Unlock()
+	}
+}
+
+// retrySync puts the Shared Drive back in the pending set and postpones its next sync.
+// The delay doubles after every consecutive failure, up to the maxSyncRetryDelay.
+func (receiver *PushReceiver) retrySync(driveID string) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	delay := receiver.config.retryDelay
+	if b, ok := receiver.backoffs[driveID]; ok {
+		delay = 2 * b.delay
+	}
+
+	if delay > maxSyncRetryDelay {
+		delay = maxSyncRetryDelay
+	}
+
+	receiver.pending[driveID] = true
+	receiver.backoffs[driveID] = backoff{at: time.Now().Add(delay), delay: delay}
+}
+
+// renew replaces every channel which is about to expire with a new channel.
+//
+// When the renewal fails, it is retried after the renewRetryDelay,
+// until the channel has expired and is removed.
+func (receiver *PushReceiver) renew(ctx context.Context) {
+	var expiring []Channel
+
+	receiver.mu.Lock()
+	now := time.Now()
+	for _, channel := range receiver.channels {
+		if !receiver.renewAt(channel).After(now) {
+			expiring = append(expiring, *channel)
+		}
+	}
+	receiver.mu.Unlock()
+
+	for _, channel := range expiring {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Register the new channel first, so no notifications are missed.
+		_, err := receiver.Register(ctx, channel.DriveID)
+		if err != nil {
+			receiver.report(channel.DriveID, err)
+			receiver.retry(channel)
+			continue
+		}
+
+		err = receiver.Stop(ctx, channel.ID)
+		if err != nil {
+			receiver.report(channel.DriveID, err)
+		}
+	}
+}
+
+// retry schedules the next renewal attempt of the channel,
+// or removes the channel when it has expired.
+func (receiver *PushReceiver) retry(channel Channel) {
+	now := time.Now()
+
+	if !channel.Expiration.After(now) {
+		receiver.mu.Lock()
+		delete(receiver.channels, channel.ID)
+		delete(receiver.retries, channel.ID)
+		receiver.mu.Unlock()
+
+		receiver.report(channel.DriveID, ErrChannelExpired)
+		return
+	}
+
+	// Retry no later than the expiration, so an expired channel is removed in time.
+	retry := now.Add(renewRetryDelay)
+	if retry.After(channel.Expiration) {
+		retry = channel.Expiration
+	}
+
+	receiver.mu.Lock()
+	if _, ok := receiver.channels[channel.ID]; ok {
+		receiver.retries[channel.ID] = retry
+	}
+	receiver.mu.Unlock()
+}
+
+func (receiver *PushReceiver) report(driveID string, err error) {
+	if receiver.config.onError != nil {
+		receiver.config.onError(driveID, err)
+	}
+}
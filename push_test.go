@@ -0,0 +1,351 @@
+package bernard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+func TestPushReceiver(t *testing.T) {
+	var watched int
+	stopped := make(chan string, 1)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/changes/watch":
+			request := new(channelRequest)
+			if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+				t.Errorf("Invalid watch request: %s", err.Error())
+			}
+
+			if request.Type != "web_hook" || request.Address != "https://bernard.test/notify" || request.Token == "" {
+				t.Errorf("Unexpected watch request: %+v", request)
+			}
+
+			if r.URL.Query().Get("pageToken") != "100" {
+				t.Errorf("Watching the wrong pageToken: %s", r.URL.Query().Get("pageToken"))
+			}
+
+			// the first channel expires soon and must be renewed
+			expiration := time.Now().Add(time.Hour)
+			if watched == 0 {
+				expiration = time.Now().Add(time.Second)
+			}
+
+			watched++
+
+			json.NewEncoder(w).Encode(channelRequest{
+				ID:         request.ID,
+				ResourceID: "resource",
+				Expiration: expiration.UnixNano() / int64(time.Millisecond),
+			})
+		case "/channels/stop":
+			request := new(channelRequest)
+			json.NewDecoder(r.Body).Decode(request)
+
+			stopped <- request.ID
+			w.WriteHeader(http.StatusNoContent)
+		case "/changes":
+			http.ServeFile(w, r, "testdata/changed-content/fields.json")
+		default:
+			http.NotFound(w, r)
+		}
+	}
+
+	fetch, server, _ := setupTest(handler)
+	defer server.Close()
+
+	store := newMockStore()
+	store.pageTokens[driveID] = "100"
+
+	synced := make(chan ds.Drive, 1)
+	hook := func(drive ds.Drive, files []ds.File, folders []ds.Folder, removed []string) error {
+		synced <- drive
+		return nil
+	}
+
+	bernard := &Bernard{fetch: fetch, store: store}
+	receiver := bernard.NewPushReceiver("https://bernard.test/notify",
+		PushRenewBefore(time.Minute),
+		PushHooks(hook),
+		PushErrors(func(driveID string, err error) {
+			t.Errorf("Unexpected error for %s: %s", driveID, err.Error())
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	old, err := receiver.Register(ctx, driveID)
+	if err != nil {
+		t.Fatalf("Could not register channel: %s", err.Error())
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- receiver.Run(ctx)
+	}()
+
+	select {
+	case id := <-stopped:
+		if id != old.ID {
+			t.Errorf("Stopped the wrong channel: %s", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The channel was not renewed")
+	}
+
+	channels := receiver.Channels()
+	if len(channels) != 1 || channels[0].ID == old.ID {
+		t.Fatalf("Unexpected channels after renewal: %+v", channels)
+	}
+
+	channel := channels[0]
+
+	notify := func(id, token, resourceID, state string) int {
+		req := httptest.NewRequest("POST", "/notify", nil)
+		req.Header.Set("X-Goog-Channel-ID", id)
+		req.Header.Set("X-Goog-Channel-Token", token)
+		req.Header.Set("X-Goog-Resource-ID", resourceID)
+		req.Header.Set("X-Goog-Resource-State", state)
+
+		rec := httptest.NewRecorder()
+		receiver.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	type test struct {
+		name       string
+		id         string
+		token      string
+		resourceID string
+		state      string
+		status     int
+	}
+
+	var testCases = []test{
+		{"expired channel", old.ID, old.Token, "resource", "change", 404},
+		{"unknown channel", "unknown", channel.Token, "resource", "change", 404},
+		{"invalid token", channel.ID, "invalid", "resource", "change", 403},
+		{"invalid resource", channel.ID, channel.Token, "invalid", "change", 403},
+		{"sync message", channel.ID, channel.Token, "resource", "sync", 200},
+	}
+
+	for _, tc := range testCases {
+		if status := notify(tc.id, tc.token, tc.resourceID, tc.state); status != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.name, tc.status, status)
+		}
+	}
+
+	select {
+	case <-synced:
+		t.Fatal("Synced without a valid change notification")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if status := notify(channel.ID, channel.Token, "resource", "change"); status != 200 {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+
+	select {
+	case drive := <-synced:
+		if drive.ID != driveID {
+			t.Errorf("Synced the wrong drive: %s", drive.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The notification did not result in a sync")
+	}
+
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestPushRegisterSync(t *testing.T) {
+	var receiver *PushReceiver
+	var status int
+
+	// Google Drive sends the sync message before the watch request has returned.
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		request := new(channelRequest)
+		json.NewDecoder(r.Body).Decode(request)
+
+		req := httptest.NewRequest("POST", "/notify", nil)
+		req.Header.Set("X-Goog-Channel-ID", request.ID)
+		req.Header.Set("X-Goog-Channel-Token", request.Token)
+		req.Header.Set("X-Goog-Resource-ID", "resource")
+		req.Header.Set("X-Goog-Resource-State", "sync")
+
+		rec := httptest.NewRecorder()
+		receiver.ServeHTTP(rec, req)
+		status = rec.Code
+
+		json.NewEncoder(w).Encode(channelRequest{ID: request.ID, ResourceID: "resource"})
+	}
+
+	fetch, server, _ := setupTest(handler)
+	defer server.Close()
+
+	store := newMockStore()
+	store.pageTokens[driveID] = "100"
+
+	bernard := &Bernard{fetch: fetch, store: store}
+	receiver = bernard.NewPushReceiver("https://bernard.test/notify")
+
+	channel, err := receiver.Register(context.Background(), driveID)
+	if err != nil {
+		t.Fatalf("Could not register channel: %s", err.Error())
+	}
+
+	if status != 200 {
+		t.Errorf("Expected status 200 for the sync message, got %d", status)
+	}
+
+	if channels := receiver.Channels(); len(channels) != 1 || channels[0] != *channel {
+		t.Errorf("Unexpected channels after registration: %+v", channels)
+	}
+}
+
+func TestPushRenewFailure(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}
+
+	fetch, server, _ := setupTest(handler)
+	defer server.Close()
+
+	store := newMockStore()
+	store.pageTokens[driveID] = "100"
+
+	var reported []error
+
+	bernard := &Bernard{fetch: fetch, store: store}
+	receiver := bernard.NewPushReceiver("https://bernard.test/notify",
+		PushRenewBefore(time.Minute),
+		PushErrors(func(_ string, err error) {
+			reported = append(reported, err)
+		}),
+	)
+
+	// Register fails, so the channel cannot be registered either.
+	if _, err := receiver.Register(context.Background(), driveID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected a not found error, got: %v", err)
+	}
+
+	if channels := receiver.Channels(); len(channels) != 0 {
+		t.Fatalf("A failed registration must not leave a channel behind: %+v", channels)
+	}
+
+	expiration := time.Now().Add(30 * time.Second)
+	receiver.channels["expiring"] = &Channel{ID: "expiring", DriveID: driveID, Expiration: expiration}
+	receiver.channels["expired"] = &Channel{ID: "expired", DriveID: driveID, Expiration: time.Now().Add(-time.Second)}
+
+	receiver.renew(context.Background())
+
+	channels := receiver.Channels()
+	if len(channels) != 1 || channels[0].ID != "expiring" {
+		t.Fatalf("Only the expired channel should be removed: %+v", channels)
+	}
+
+	// The real expiration is kept, the retry is tracked separately.
+	if !channels[0].Expiration.Equal(expiration) {
+		t.Errorf("The expiration must not change, got: %v", channels[0].Expiration)
+	}
+
+	retry, ok := receiver.retries["expiring"]
+	if !ok || retry.After(expiration) || !retry.After(time.Now()) {
+		t.Errorf("Unexpected retry time %v for expiration %v", retry, expiration)
+	}
+
+	if next := receiver.nextWake(); next <= 0 || next > 30*time.Second {
+		t.Errorf("The next renewal should be at the retry, got: %v", next)
+	}
+
+	var expired int
+	for _, err := range reported {
+		if errors.Is(err, ErrChannelExpired) {
+			expired++
+		}
+	}
+
+	if len(reported) != 3 || expired != 1 {
+		t.Errorf("Unexpected reported errors: %v", reported)
+	}
+}
+
+func TestPushSyncBackoff(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/changed-content/fields.json")
+	}
+
+	fetch, server, _ := setupTest(handler)
+	defer server.Close()
+
+	store := newMockStore()
+	store.pageTokens[driveID] = "100"
+	store.partialErrs = []error{ds.ErrDataAnomaly, ds.ErrDataAnomaly}
+
+	var reported int
+
+	bernard := &Bernard{fetch: fetch, store: store}
+	receiver := bernard.NewPushReceiver("https://bernard.test/notify",
+		PushRetryDelay(time.Minute),
+		PushErrors(func(string, error) {
+			reported++
+		}),
+	)
+
+	receiver.pending[driveID] = true
+
+	type test struct {
+		name    string
+		due     bool
+		pending bool
+		delay   time.Duration
+		errs    int
+	}
+
+	var testCases = []test{
+		{"first failure", true, true, time.Minute, 1},
+		{"backoff not due", false, true, time.Minute, 1},
+		{"second failure", true, true, 2 * time.Minute, 0},
+		{"success", true, false, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		if b, ok := receiver.backoffs[driveID]; ok && tc.due {
+			receiver.backoffs[driveID] = backoff{at: time.Now(), delay: b.delay}
+		}
+
+		receiver.syncPending(context.Background())
+
+		if receiver.pending[driveID] != tc.pending {
+			t.Errorf("%s: expected pending %t", tc.name, tc.pending)
+		}
+
+		if receiver.backoffs[driveID].delay != tc.delay {
+			t.Errorf("%s: expected delay %v, got %v", tc.name, tc.delay, receiver.backoffs[driveID].delay)
+		}
+
+		if len(store.partialErrs) != tc.errs {
+			t.Errorf("%s: expected %d remaining sync errors, got %d", tc.name, tc.errs, len(store.partialErrs))
+		}
+
+		// Run wakes up for the retry of the failed sync.
+		if next := receiver.nextWake(); tc.pending && next > tc.delay {
+			t.Errorf("%s: the next wake should be at the retry, got: %v", tc.name, next)
+		}
+	}
+
+	if reported != 2 {
+		t.Errorf("Expected 2 reported errors, got %d", reported)
+	}
+}
@@ -0,0 +1,142 @@
+package bernard
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy defines which failed requests to the Drive API are retried,
+// and how long to wait before every retry.
+//
+// The wait before the nth retry starts at the InitialInterval and doubles
+// with every backoff, up to the MaxInterval. Switching to another account of a
+// RateLimitedAuthenticator does not wait and does not double the wait. A Retry-After header of the response
+// takes precedence over this exponential backoff, unless IgnoreRetryAfter is set.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts of a request, including the first.
+	// Immediate retries with another account of a RateLimitedAuthenticator are counted as well.
+	// There is no maximum when MaxAttempts is 0.
+	MaxAttempts int
+
+	// MaxElapsedTime is the maximum time since the first attempt a retry may start at.
+	// There is no maximum when MaxElapsedTime is 0.
+	MaxElapsedTime time.Duration
+
+	// InitialInterval is the wait before the first retry, 1 second when 0.
+	InitialInterval time.Duration
+
+	// MaxInterval is the maximum wait of the exponential backoff, 32 seconds when 0.
+	MaxInterval time.Duration
+
+	// Jitter randomly spreads every wait by up to the fraction of the wait,
+	// so concurrent requests do not all retry at once. A Jitter of 0.2 turns
+	// a wait of 10 seconds into a wait between 8 and 12 seconds.
+	Jitter float64
+
+	// IgnoreRetryAfter makes the policy ignore the Retry-After header of a response.
+	IgnoreRetryAfter bool
+
+	// Retryable reports whether a response with the status code and the reason
+	// of its first error should be retried. DefaultRetryable is used when nil.
+	Retryable func(statusCode int, reason string) bool
+}
+
+// DefaultRetryPolicy is the RetryPolicy Bernard uses unless overridden with WithRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:     10,
+	MaxElapsedTime:  10 * time.Minute,
+	InitialInterval: time.Second,
+	MaxInterval:     32 * time.Second,
+	Retryable:       DefaultRetryable,
+}
+
+// DefaultRetryable reports whether the response is retryable, which is the case
+// for rate-limits (403 and 429) and server errors (500, 502, 503 and 504).
+func DefaultRetryable(statusCode int, reason string) bool {
+	switch statusCode {
+	case 429, 500, 502, 503, 504:
+		return true
+	case 403:
+		return isRateLimit(reason)
+	default:
+		return false
+	}
+}
+
+// isRateLimit reports whether the reason of a 403 response is a rate-limit.
+func isRateLimit(reason string) bool {
+	return reason == "userRateLimitExceeded" || reason == "rateLimitExceeded"
+}
+
+func (policy *RetryPolicy) retryable(statusCode int, reason string) bool {
+	if policy.Retryable == nil {
+		return DefaultRetryable(statusCode, reason)
+	}
+
+	return policy.Retryable(statusCode, reason)
+}
+
+// backoff returns the wait before the retry, where retry is 0 for the first retry.
+func (policy *RetryPolicy) backoff(retry int, res *http.Response) time.Duration {
+	if !policy.IgnoreRetryAfter {
+		if wait, ok := retryAfter(res); ok {
+			return wait
+		}
+	}
+
+	initial, max := policy.InitialInterval, policy.MaxInterval
+	if initial <= 0 {
+		initial = time.Second
+	}
+
+	if max <= 0 {
+		max = 32 * time.Second
+	}
+
+	wait := max
+	if exp := math.Exp2(float64(retry)); exp <= float64(max/initial) {
+		wait = time.Duration(exp) * initial
+	}
+
+	if policy.Jitter > 0 {
+		wait += time.Duration(policy.Jitter * (2*rand.Float64() - 1) * float64(wait))
+	}
+
+	return wait
+}
+
+// exhausted reports whether another attempt would exceed the budget of the policy,
+// after the number of attempts and with the elapsed time at the start of the next attempt.
+func (policy *RetryPolicy) exhausted(attempts int, elapsed time.Duration) bool {
+	if policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts {
+		return true
+	}
+
+	return policy.MaxElapsedTime > 0 && elapsed > policy.MaxElapsedTime
+}
+
+// retryAfter parses the Retry-After header, which holds either seconds or an HTTP date.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	header := res.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+
+		return wait, true
+	}
+
+	return 0, false
+}
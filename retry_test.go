@@ -0,0 +1,133 @@
+package bernard
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	response := func(retryAfter string) *http.Response {
+		res := &http.Response{Header: http.Header{}}
+		if retryAfter != "" {
+			res.Header.Set("Retry-After", retryAfter)
+		}
+
+		return res
+	}
+
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		retry      int
+		retryAfter string
+		min, max   time.Duration
+	}{
+		{name: "first retry", policy: DefaultRetryPolicy, retry: 0, min: time.Second, max: time.Second},
+		{name: "third retry", policy: DefaultRetryPolicy, retry: 2, min: 4 * time.Second, max: 4 * time.Second},
+		{name: "max interval", policy: DefaultRetryPolicy, retry: 20, min: 32 * time.Second, max: 32 * time.Second},
+		{name: "zero policy", policy: RetryPolicy{}, retry: 6, min: 32 * time.Second, max: 32 * time.Second},
+		{
+			name:   "custom intervals",
+			policy: RetryPolicy{InitialInterval: 100 * time.Millisecond, MaxInterval: time.Second},
+			retry:  3,
+			min:    800 * time.Millisecond,
+			max:    800 * time.Millisecond,
+		},
+		{name: "jitter", policy: RetryPolicy{Jitter: 0.5}, retry: 2, min: 2 * time.Second, max: 6 * time.Second},
+		{name: "retry after seconds", policy: DefaultRetryPolicy, retryAfter: "120", min: 2 * time.Minute, max: 2 * time.Minute},
+		{
+			name:       "retry after date",
+			policy:     DefaultRetryPolicy,
+			retryAfter: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat),
+			min:        58 * time.Minute,
+			max:        time.Hour,
+		},
+		{name: "invalid retry after", policy: DefaultRetryPolicy, retryAfter: "soon", min: time.Second, max: time.Second},
+		{
+			name:       "ignore retry after",
+			policy:     RetryPolicy{IgnoreRetryAfter: true},
+			retryAfter: "120",
+			min:        time.Second,
+			max:        time.Second,
+		},
+	}
+
+	for _, tc := range tests {
+		for i := 0; i < 10; i++ {
+			wait := tc.policy.backoff(tc.retry, response(tc.retryAfter))
+			if wait < tc.min || wait > tc.max {
+				t.Errorf("%s: wait %v not between %v and %v", tc.name, wait, tc.min, tc.max)
+				break
+			}
+		}
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		retryAfter string
+		sleep      int
+	}{
+		{name: "max attempts", policy: RetryPolicy{MaxAttempts: 3}, sleep: 2},
+		// The mocked sleep does not pass any time, so the backoff of 16 seconds is the first to exceed it.
+		{name: "max elapsed time", policy: RetryPolicy{MaxElapsedTime: 10 * time.Second}, sleep: 4},
+		{name: "retry after exceeds max elapsed time", policy: RetryPolicy{MaxElapsedTime: time.Minute}, retryAfter: "3600"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				if tc.retryAfter != "" {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+
+				w.WriteHeader(503)
+			}
+
+			fetch, server, sleep := setupTest(handler)
+			defer server.Close()
+
+			fetch.retry = tc.policy
+
+			req, _ := http.NewRequest("GET", fetch.baseURL, nil)
+			if _, err := fetch.withAuth(req); !errors.Is(err, ErrRetriesExhausted) {
+				t.Fatalf("Expected ErrRetriesExhausted, got: %v", err)
+			}
+
+			if sleep.called != tc.sleep {
+				t.Errorf("Expected %d backoffs, got: %d", tc.sleep, sleep.called)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	var called int
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		called++
+		w.WriteHeader(500)
+	}
+
+	fetch, server, sleep := setupTest(handler)
+	defer server.Close()
+
+	fetch.retry = RetryPolicy{
+		Retryable: func(statusCode int, reason string) bool {
+			return statusCode == 503
+		},
+	}
+
+	req, _ := http.NewRequest("GET", fetch.baseURL, nil)
+	if _, err := fetch.withAuth(req); !errors.Is(err, ErrNetwork) {
+		t.Fatalf("Expected ErrNetwork, got: %v", err)
+	}
+
+	if called != 1 || sleep.called != 0 {
+		t.Errorf("Expected no retries, got %d requests and %d backoffs", called, sleep.called)
+	}
+}
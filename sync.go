@@ -1,12 +1,30 @@
 package bernard
 
 import (
+	"context"
+	"errors"
+
 	ds "github.com/m-rots/bernard/datastore"
 )
 
 // FullSync syncs the entire content of Drive to the datastore.
 func (bernard *Bernard) FullSync(driveID string) error {
-	startPageToken, err := bernard.fetch.pageToken(driveID)
+	return bernard.FullSyncContext(context.Background(), driveID)
+}
+
+// FullSyncContext syncs the entire content of Drive to the datastore.
+//
+// If the datastore implements the StreamingDatastore interface, every page of content
+// is written to the datastore as soon as it is fetched. If the datastore implements the
+// ResumableDatastore interface as well, an interrupted full sync can be continued
+// with ResumeFullSync.
+//
+// The provided context is used for every request to Google Drive, the sleeps in-between
+// retries and the datastore operations. When the context is cancelled, the sync is aborted
+// and the context's error is returned. Any changes not yet committed to the datastore
+// are rolled back.
+func (bernard *Bernard) FullSyncContext(ctx context.Context, driveID string) error {
+	startPageToken, err := bernard.fetch.pageToken(ctx, driveID)
 	if err != nil {
 		return err
 	}
@@ -14,10 +32,13 @@ func (bernard *Bernard) FullSync(driveID string) error {
 	// To prevent possible missing data, a sleep of 1-5 minutes
 	// between the pageToken and FullSync can be enabled.
 	if bernard.safeSleep > 0 {
-		bernard.fetch.sleep(bernard.safeSleep)
+		err = bernard.fetch.sleep(ctx, bernard.safeSleep)
+		if err != nil {
+			return err
+		}
 	}
 
-	name, err := bernard.fetch.drive(driveID)
+	name, err := bernard.fetch.drive(ctx, driveID)
 	if err != nil {
 		return err
 	}
@@ -28,12 +49,32 @@ func (bernard *Bernard) FullSync(driveID string) error {
 		PageToken: startPageToken,
 	}
 
-	folders, files, err := bernard.fetch.allContent(driveID)
+	// List the folders concurrently if a parallel full sync is enabled.
+	if bernard.workers > 1 {
+		folders, files, err := bernard.fetch.parallelContent(ctx, driveID, bernard.workers)
+		if err != nil {
+			return err
+		}
+
+		return bernard.storeFullSync(ctx, drive, folders, files)
+	}
+
+	// Stream the content page by page if the datastore supports it.
+	if store, ok := bernard.store.(ds.StreamingDatastore); ok {
+		writer, err := store.BeginFullSync(ctx, drive)
+		if err != nil {
+			return err
+		}
+
+		return bernard.streamFullSync(ctx, writer, driveID, "")
+	}
+
+	folders, files, err := bernard.fetch.allContent(ctx, driveID)
 	if err != nil {
 		return err
 	}
 
-	err = bernard.store.FullSync(drive, folders, files)
+	err = bernard.storeFullSync(ctx, drive, folders, files)
 	if err != nil {
 		return err
 	}
@@ -41,6 +82,60 @@ func (bernard *Bernard) FullSync(driveID string) error {
 	return nil
 }
 
+// ResumeFullSync resumes an interrupted full sync of the Drive.
+func (bernard *Bernard) ResumeFullSync(driveID string) error {
+	return bernard.ResumeFullSyncContext(context.Background(), driveID)
+}
+
+// ResumeFullSyncContext resumes an interrupted full sync of the Drive.
+//
+// If the datastore implements the ResumableDatastore interface, the full sync continues
+// at the first page which has not been written to the datastore yet.
+// A new full sync is started when the datastore is not resumable,
+// or when no interrupted full sync of the Drive exists.
+func (bernard *Bernard) ResumeFullSyncContext(ctx context.Context, driveID string) error {
+	store, ok := bernard.store.(ds.ResumableDatastore)
+	if !ok {
+		return bernard.FullSyncContext(ctx, driveID)
+	}
+
+	checkpoint, err := store.Checkpoint(ctx, driveID)
+	if errors.Is(err, ds.ErrNoCheckpoint) {
+		return bernard.FullSyncContext(ctx, driveID)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	writer, err := store.ResumeFullSync(ctx, checkpoint)
+	if err != nil {
+		return err
+	}
+
+	// All pages have been written, only the commit remains.
+	if checkpoint.Pages > 0 && checkpoint.NextPageToken == "" {
+		return writer.Commit(ctx)
+	}
+
+	return bernard.streamFullSync(ctx, writer, driveID, checkpoint.NextPageToken)
+}
+
+// streamFullSync writes every page of content to the datastore as soon as it is fetched,
+// starting at the page of the provided pageToken.
+func (bernard *Bernard) streamFullSync(ctx context.Context, writer ds.FullSyncWriter, driveID string, pageToken string) error {
+	err := bernard.fetch.contentPages(ctx, driveID, pageToken, func(folders []ds.Folder, files []ds.File, nextPageToken string) error {
+		return writer.WritePage(ctx, folders, files, nextPageToken)
+	})
+
+	if err != nil {
+		writer.Rollback()
+		return err
+	}
+
+	return writer.Commit(ctx)
+}
+
 // Hook allows the injection of functions between the fetch and datastore operations.
 //
 // The hook provides the changes as provided by Google, which could contain data anomalies.
@@ -65,12 +160,21 @@ type Hook = func(drive ds.Drive, files []ds.File, folders []ds.Folder, removed [
 //
 // Optionally, you can provide one or multiple Hooks to get insight into the fetched changes.
 func (bernard *Bernard) PartialSync(driveID string, hooks ...Hook) error {
-	pageToken, err := bernard.store.PageToken(driveID)
+	return bernard.PartialSyncContext(context.Background(), driveID, hooks...)
+}
+
+// PartialSyncContext syncs the latest changes within the Drive to the underlying datastore.
+//
+// The provided context is used for every request to Google Drive, the sleeps in-between
+// retries and the datastore operations. When the context is cancelled, the sync is aborted
+// and the context's error is returned. The hooks are not called once the context is cancelled.
+func (bernard *Bernard) PartialSyncContext(ctx context.Context, driveID string, hooks ...Hook) error {
+	pageToken, err := bernard.storePageToken(ctx, driveID)
 	if err != nil {
 		return err
 	}
 
-	diff, err := bernard.fetch.changedContent(driveID, pageToken)
+	diff, err := bernard.fetch.changedContent(ctx, driveID, pageToken)
 	if err != nil {
 		return err
 	}
@@ -80,16 +184,62 @@ func (bernard *Bernard) PartialSync(driveID string, hooks ...Hook) error {
 	}
 
 	for _, hk := range hooks {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
 		err = hk(diff.Drive, diff.ChangedFiles, diff.ChangedFolders, diff.RemovedIDs)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = bernard.store.PartialSync(diff.Drive, diff.ChangedFolders, diff.ChangedFiles, diff.RemovedIDs)
+	err = bernard.storePartialSync(ctx, diff.Drive, diff.ChangedFolders, diff.ChangedFiles, diff.RemovedIDs)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// storeFullSync passes the context to the datastore if it implements the ContextDatastore.
+// Otherwise, the context is only checked before the datastore is called.
+func (bernard *Bernard) storeFullSync(ctx context.Context, drive ds.Drive, folders []ds.Folder, files []ds.File) error {
+	if store, ok := bernard.store.(ds.ContextDatastore); ok {
+		return store.FullSyncContext(ctx, drive, folders, files)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return bernard.store.FullSync(drive, folders, files)
+}
+
+// storePartialSync passes the context to the datastore if it implements the ContextDatastore.
+// Otherwise, the context is only checked before the datastore is called.
+func (bernard *Bernard) storePartialSync(ctx context.Context, drive ds.Drive, folders []ds.Folder, files []ds.File, removedIDs []string) error {
+	if store, ok := bernard.store.(ds.ContextDatastore); ok {
+		return store.PartialSyncContext(ctx, drive, folders, files, removedIDs)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return bernard.store.PartialSync(drive, folders, files, removedIDs)
+}
+
+// storePageToken passes the context to the datastore if it implements the ContextDatastore.
+// Otherwise, the context is only checked before the datastore is called.
+func (bernard *Bernard) storePageToken(ctx context.Context, driveID string) (string, error) {
+	if store, ok := bernard.store.(ds.ContextDatastore); ok {
+		return store.PageTokenContext(ctx, driveID)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	return bernard.store.PageToken(driveID)
+}
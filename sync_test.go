@@ -0,0 +1,179 @@
+package bernard
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// mockStore is a ResumableDatastore which keeps the written pages in memory.
+type mockStore struct {
+	pageTokens map[string]string
+	checkpoint *ds.Checkpoint
+
+	// partialErrs are returned by the next calls to PartialSync
+	partialErrs []error
+
+	folders []ds.Folder
+	files   []ds.File
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{pageTokens: make(map[string]string)}
+}
+
+func (store *mockStore) FullSync(drive ds.Drive, folders []ds.Folder, files []ds.File) error {
+	store.pageTokens[drive.ID] = drive.PageToken
+	store.folders = folders
+	store.files = files
+	return nil
+}
+
+func (store *mockStore) PartialSync(drive ds.Drive, folders []ds.Folder, files []ds.File, removedIDs []string) error {
+	if len(store.partialErrs) > 0 {
+		err := store.partialErrs[0]
+		store.partialErrs = store.partialErrs[1:]
+		return err
+	}
+
+	store.pageTokens[drive.ID] = drive.PageToken
+	store.folders = append(store.folders, folders...)
+	store.files = append(store.files, files...)
+	return nil
+}
+
+func (store *mockStore) PageToken(driveID string) (string, error) {
+	pageToken, ok := store.pageTokens[driveID]
+	if !ok {
+		return "", ds.ErrFullSync
+	}
+
+	return pageToken, nil
+}
+
+func (store *mockStore) BeginFullSync(ctx context.Context, drive ds.Drive) (ds.FullSyncWriter, error) {
+	store.checkpoint = &ds.Checkpoint{Drive: drive}
+	store.folders = nil
+	store.files = nil
+	return store, nil
+}
+
+func (store *mockStore) Checkpoint(ctx context.Context, driveID string) (ds.Checkpoint, error) {
+	if store.checkpoint == nil || store.checkpoint.Drive.ID != driveID {
+		return ds.Checkpoint{}, ds.ErrNoCheckpoint
+	}
+
+	return *store.checkpoint, nil
+}
+
+func (store *mockStore) ResumeFullSync(ctx context.Context, checkpoint ds.Checkpoint) (ds.FullSyncWriter, error) {
+	return store, nil
+}
+
+func (store *mockStore) WritePage(ctx context.Context, folders []ds.Folder, files []ds.File, nextPageToken string) error {
+	store.folders = append(store.folders, folders...)
+	store.files = append(store.files, files...)
+	store.checkpoint.NextPageToken = nextPageToken
+	store.checkpoint.Pages++
+	return nil
+}
+
+func (store *mockStore) Commit(ctx context.Context) error {
+	store.pageTokens[store.checkpoint.Drive.ID] = store.checkpoint.Drive.PageToken
+	store.checkpoint = nil
+	return nil
+}
+
+func (store *mockStore) Rollback() error {
+	return nil
+}
+
+// fixtureHandler serves the fixtures of the pageToken and drive endpoints.
+// The files endpoint serves the first page from the provided fixture and any following
+// pages from the fixture the pageToken points to.
+func fixtureHandler(filesFixture string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/changes/startPageToken":
+			http.ServeFile(w, r, "testdata/page-token/basic.json")
+		case strings.HasPrefix(r.URL.Path, "/drives/"):
+			http.ServeFile(w, r, "testdata/drive/basic.json")
+		case r.URL.Path == "/files":
+			fixturePath := filesFixture
+			if pageToken := r.URL.Query().Get("pageToken"); pageToken != "" {
+				fixturePath = pageToken
+			}
+
+			http.ServeFile(w, r, fixturePath)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func TestResumeFullSync(t *testing.T) {
+	var interrupt = true
+	var requestedPages []string
+
+	files := fixtureHandler("testdata/all-content/pageToken.json")
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/files" {
+			pageToken := r.URL.Query().Get("pageToken")
+			requestedPages = append(requestedPages, pageToken)
+
+			// interrupt the full sync at the second page
+			if pageToken != "" && interrupt {
+				interrupt = false
+				w.WriteHeader(404)
+				return
+			}
+		}
+
+		files(w, r)
+	}
+
+	fetch, server, _ := setupTest(handler)
+	defer server.Close()
+
+	store := newMockStore()
+	bernard := &Bernard{fetch: fetch, store: store}
+
+	err := bernard.FullSync(driveID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if store.checkpoint == nil || store.checkpoint.Pages != 1 {
+		t.Fatalf("Unexpected checkpoint: %v", store.checkpoint)
+	}
+
+	err = bernard.ResumeFullSync(driveID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedPages := []string{"", "testdata/all-content/pageToken2.json", "testdata/all-content/pageToken2.json"}
+	if !reflect.DeepEqual(requestedPages, expectedPages) {
+		t.Log(requestedPages)
+		t.Errorf("Requested pages do not match, the first page should not be fetched twice")
+	}
+
+	expectedFiles := []ds.File{
+		{ID: "Z", Parent: driveID},
+		{ID: "X", Parent: driveID},
+	}
+
+	if !reflect.DeepEqual(store.files, expectedFiles) {
+		t.Log(store.files)
+		t.Errorf("Files do not match the expected output")
+	}
+
+	if pageToken, _ := store.PageToken(driveID); pageToken != "100" {
+		t.Errorf("Wrong pageToken saved: %s", pageToken)
+	}
+}
@@ -0,0 +1,141 @@
+package bernard
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+// watcher holds the configuration of Watch.
+type watcher struct {
+	interval   time.Duration
+	jitter     time.Duration
+	retryDelay time.Duration
+
+	hooks   []Hook
+	onError func(driveID string, err error)
+}
+
+// A WatchOption can override some of the default Watch values.
+type WatchOption func(*watcher)
+
+// WatchInterval sets the time between two partial syncs of the same Shared Drive.
+//
+// The default interval is set at 1 minute.
+func WatchInterval(interval time.Duration) WatchOption {
+	return func(w *watcher) {
+		w.interval = interval
+	}
+}
+
+// WatchJitter adds a random duration between 0 and the provided jitter
+// to every interval and retry delay. This prevents multiple Shared Drives from being polled
+// at the exact same moment.
+//
+// The default jitter is set at 0.
+func WatchJitter(jitter time.Duration) WatchOption {
+	return func(w *watcher) {
+		w.jitter = jitter
+	}
+}
+
+// WatchRetryDelay sets the time to wait before retrying a sync which resulted in a
+// data anomaly. As Google Drive needs some time to process all changes,
+// an immediate retry would likely result in the same anomaly.
+//
+// The default retry delay is set at 30 seconds.
+func WatchRetryDelay(delay time.Duration) WatchOption {
+	return func(w *watcher) {
+		w.retryDelay = delay
+	}
+}
+
+// WatchHooks provides the Hooks to run on every partial sync.
+//
+// When multiple Shared Drives are watched, the hooks are called concurrently
+// and must therefore be safe for concurrent use.
+func WatchHooks(hooks ...Hook) WatchOption {
+	return func(w *watcher) {
+		w.hooks = hooks
+	}
+}
+
+// WatchErrors provides a function which is called with every sync error.
+// Watch keeps polling the Shared Drive after an error has occurred.
+//
+// When multiple Shared Drives are watched, the function is called concurrently.
+func WatchErrors(onError func(driveID string, err error)) WatchOption {
+	return func(w *watcher) {
+		w.onError = onError
+	}
+}
+
+// Watch continuously synchronises the provided Shared Drives until the context is cancelled.
+//
+// Every interval, the latest changes of each Shared Drive are synchronised with a partial sync.
+// A Shared Drive which has not been fully synchronised yet is fully synchronised first.
+// When a sync results in a data anomaly, the sync is retried after the retry delay
+// instead of the interval.
+//
+// Watch always returns the error of the context.
+func (bernard *Bernard) Watch(ctx context.Context, driveIDs []string, opts ...WatchOption) error {
+	w := &watcher{
+		interval:   time.Minute,
+		retryDelay: 30 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	var wg sync.WaitGroup
+
+	for _, driveID := range driveIDs {
+		wg.Add(1)
+
+		go func(driveID string) {
+			defer wg.Done()
+			bernard.watchDrive(ctx, w, driveID)
+		}(driveID)
+	}
+
+	wg.Wait()
+	return ctx.Err()
+}
+
+// watchDrive synchronises a single Shared Drive until the context is cancelled.
+func (bernard *Bernard) watchDrive(ctx context.Context, w *watcher, driveID string) {
+	for {
+		err := bernard.PartialSyncContext(ctx, driveID, w.hooks...)
+		if errors.Is(err, ds.ErrFullSync) {
+			err = bernard.FullSyncContext(ctx, driveID)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		wait := w.interval
+		if err != nil {
+			if errors.Is(err, ds.ErrDataAnomaly) {
+				wait = w.retryDelay
+			}
+
+			if w.onError != nil {
+				w.onError(driveID, err)
+			}
+		}
+
+		if w.jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(w.jitter)))
+		}
+
+		if bernard.fetch.sleep(ctx, wait) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package bernard
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	ds "github.com/m-rots/bernard/datastore"
+)
+
+func TestWatchJitter(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/changed-content/fields.json")
+	}
+
+	fetch, server, sleep := setupTest(handler)
+	defer server.Close()
+
+	store := newMockStore()
+	store.pageTokens[driveID] = "100"
+	store.partialErrs = []error{ds.ErrDataAnomaly}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// stop watching at the first sleep
+	fetch.sleep = func(_ context.Context, d time.Duration) error {
+		cancel()
+		return sleep.Sleep(ctx, d)
+	}
+
+	bernard := &Bernard{fetch: fetch, store: store}
+	bernard.Watch(ctx, []string{driveID},
+		WatchRetryDelay(time.Second),
+		WatchJitter(time.Second),
+	)
+
+	// The jitter is applied to the retry delay as well.
+	if len(sleep.calledWith) != 1 || sleep.calledWith[0] < time.Second || sleep.calledWith[0] >= 2*time.Second {
+		t.Errorf("Sleep not called with a jittered retry delay: %v", sleep.calledWith)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	files := fixtureHandler("testdata/all-content/order.json")
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/changes" {
+			// the pageToken of the full sync is 100
+			http.ServeFile(w, r, "testdata/changed-content/fields.json")
+			return
+		}
+
+		files(w, r)
+	}
+
+	fetch, server, sleep := setupTest(handler)
+	defer server.Close()
+
+	store := newMockStore()
+	store.partialErrs = []error{ds.ErrDataAnomaly}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// stop watching at the third sleep
+	fetch.sleep = func(_ context.Context, d time.Duration) error {
+		if sleep.called == 2 {
+			cancel()
+		}
+
+		return sleep.Sleep(ctx, d)
+	}
+
+	var hooked int
+	hook := func(drive ds.Drive, files []ds.File, folders []ds.Folder, removed []string) error {
+		hooked++
+		return nil
+	}
+
+	var errs []error
+	onError := func(driveID string, err error) {
+		errs = append(errs, err)
+	}
+
+	bernard := &Bernard{fetch: fetch, store: store}
+
+	err := bernard.Watch(ctx, []string{driveID},
+		WatchInterval(time.Minute),
+		WatchRetryDelay(time.Second),
+		WatchHooks(hook),
+		WatchErrors(onError),
+	)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// 1. partial sync -> requires full sync -> full sync -> sleep interval
+	// 2. partial sync -> data anomaly -> sleep retry delay
+	// 3. partial sync -> sleep interval -> cancelled
+	expectedSleeps := []time.Duration{time.Minute, time.Second, time.Minute}
+	if !reflect.DeepEqual(sleep.calledWith, expectedSleeps) {
+		t.Log(sleep.calledWith)
+		t.Error("Sleep not called with right values")
+	}
+
+	if len(errs) != 1 || !errors.Is(errs[0], ds.ErrDataAnomaly) {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	if hooked != 2 {
+		t.Errorf("Hook called %d times instead of 2", hooked)
+	}
+
+	if pageToken, _ := store.PageToken(driveID); pageToken != "page token go brrr" {
+		t.Errorf("Wrong pageToken saved: %s", pageToken)
+	}
+}